@@ -2,93 +2,360 @@ package main
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
 	"github.com/LGiki/bark-tray/assets"
+	"github.com/LGiki/bark-tray/pkg/bark"
 	"github.com/LGiki/bark-tray/pkg/config"
+	"github.com/LGiki/bark-tray/pkg/hotkeys"
 	"github.com/LGiki/bark-tray/pkg/httpClient"
 	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/LGiki/bark-tray/pkg/outbox"
 	"github.com/LGiki/bark-tray/pkg/util"
 	"github.com/emersion/go-autostart"
 	"github.com/getlantern/systray"
 	"github.com/ncruces/zenity"
+	"go.uber.org/zap"
 	"golang.design/x/clipboard"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
-const (
-	configFileName = "config.json"
+// appConfig is the config in use, which is replaced as a whole when the config file is reloaded,
+// so an operation should load it once and use the loaded config throughout.
+var appConfig atomic.Pointer[config.Config]
+
+// appConfigFilePath and appDataDir are used to reload the config file.
+var (
+	appConfigFilePath string
+	appDataDir        string
 )
 
-var appConfig *config.Config
+var errNoClipboardContent = errors.New("there is no text or image content in the clipboard")
+
+// stopCh is closed when the application exits to stop the background jobs.
+var stopCh = make(chan struct{})
 
-func pushMessageFromClipboard(device *config.Device) {
+// readClipboardText reads the text content of the clipboard,
+// ok is false if there is no text content in the clipboard.
+func readClipboardText() (clipboardText string, ok bool) {
 	clipboardTextBytes := clipboard.Read(clipboard.FmtText)
 	if clipboardTextBytes == nil {
-		logger.Warn(fmt.Sprintf("There is no text content in the clipboard, sending to device '%s' (%s) failed.", device.Name, device.Key))
-		_ = zenity.Notify("There is no text content in the clipboard", zenity.InfoIcon)
+		return "", false
+	}
+	return strings.TrimSpace(string(clipboardTextBytes)), true
+}
+
+// newPushRequestFromClipboard builds the push request from the clipboard content
+// and applies profile to it if profile is not nil.
+func newPushRequestFromClipboard(profile *config.Profile) (*bark.PushRequest, error) {
+	pushRequest, err := newPushRequestFromClipboardContent()
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		if err = profile.Apply(pushRequest); err != nil {
+			return nil, fmt.Errorf("failed to apply profile '%s': %s", profile.Name, err.Error())
+		}
+	}
+	return pushRequest, nil
+}
+
+// newPushRequestFromClipboardContent builds the push request from the clipboard content.
+// The text content is preferred, if there is none, the image content is uploaded
+// to the image host and pushed as a link.
+func newPushRequestFromClipboardContent() (*bark.PushRequest, error) {
+	if clipboardText, ok := readClipboardText(); ok {
+		return config.NewTextPushRequest(clipboardText), nil
+	}
+	clipboardImageBytes := clipboard.Read(clipboard.FmtImage)
+	if clipboardImageBytes == nil {
+		return nil, errNoClipboardContent
+	}
+	imageHost := appConfig.Load().ImageHost
+	if imageHost == nil {
+		return nil, errors.New("there is no text content in the clipboard, please configure an image host to send images")
+	}
+	fileName := fmt.Sprintf("bark-tray-%s.png", time.Now().Format("20060102-150405"))
+	logger.Info("Start uploading the clipboard image", zap.String("file", fileName), zap.Int("bytes", len(clipboardImageBytes)))
+	imageUrl, err := imageHost.Upload(clipboardImageBytes, fileName, "image/png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload the clipboard image: %s", err.Error())
+	}
+	logger.Info("Successfully uploaded the clipboard image", zap.String("url", imageUrl))
+	return config.NewImagePushRequest(imageUrl), nil
+}
+
+// notifyClipboardError shows a notification for the error returned by newPushRequestFromClipboard.
+func notifyClipboardError(err error) {
+	if err == errNoClipboardContent {
+		_ = zenity.Notify("There is no text or image content in the clipboard", zenity.InfoIcon)
 		return
 	}
-	clipboardText := strings.TrimSpace(string(clipboardTextBytes))
-	logger.Info(fmt.Sprintf("Start sending `%s` to '%s' (%s)", clipboardText, device.Name, device.Key))
-	err := device.PushTextMessage(clipboardText)
+	_ = zenity.Notify("Failed to read the clipboard: "+err.Error(), zenity.ErrorIcon)
+}
+
+// pushMessageFromClipboard sends the clipboard content to device,
+// profile is applied to the push request if it is not nil.
+func pushMessageFromClipboard(device *config.Device, profile *config.Profile) {
+	pushRequest, err := newPushRequestFromClipboard(profile)
 	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to send `%s` to '%s' (%s): %s", clipboardText, device.Name, device.Key, err.Error()))
-		_ = zenity.Notify(fmt.Sprintf("Failed to send to '%s': %s", device.Name, err.Error()), zenity.ErrorIcon)
+		logger.Warn("Failed to read the clipboard", append(device.LogFields(), zap.Error(err))...)
+		notifyClipboardError(err)
+		return
+	}
+	if check := guardSecrets([]*config.Device{device}, pushRequest, true); len(check.Allowed) == 0 {
 		return
 	}
-	logger.Info(fmt.Sprintf("Successfully sent `%s` to '%s' (%s)", clipboardText, device.Name, device.Key))
+	pushToDevice(device, pushRequest)
 }
 
-func addPushMenuItems() {
-	if len(appConfig.Devices) == 0 {
-		noAnyDeviceMenuItem := systray.AddMenuItem("No device configured", "No device configured")
-		noAnyDeviceMenuItem.Disable()
+// pushToDevice sends pushRequest to device, records it in the history and the metrics,
+// and saves it to the outbox if it fails.
+func pushToDevice(device *config.Device, pushRequest *bark.PushRequest) {
+	logger.Info("Start sending", append(device.LogFields(), logger.BodyFields(pushRequest.Body)...)...)
+	startTime := time.Now()
+	pushResponse, err := device.PushWithResponse(pushRequest)
+	result := &config.PushResult{Device: device, Response: pushResponse, Err: err, Duration: time.Since(startTime)}
+	recordPush(pushRequest, config.PushResults{result})
+	recordPushMetrics(config.PushResults{result})
+	if err != nil {
+		logger.Error("Failed to send", result.LogFields()...)
+		if savePendingPush(device, pushRequest, err) {
+			_ = zenity.Notify(fmt.Sprintf("Failed to send to '%s': %s\nIt will be retried later.", device.Name, err.Error()), zenity.ErrorIcon)
+		} else {
+			_ = zenity.Notify(fmt.Sprintf("Failed to send to '%s': %s", device.Name, err.Error()), zenity.ErrorIcon)
+		}
+		return
+	}
+	logger.Info("Successfully sent", result.LogFields()...)
+}
+
+// pushToDevices sends pushRequest to devices concurrently,
+// records it in the history and the metrics, and saves it to the outbox for each device it fails to be sent to.
+func pushToDevices(devices []*config.Device, pushRequest *bark.PushRequest) config.PushResults {
+	logger.Info("Start sending", append(logger.BodyFields(pushRequest.Body), zap.Int("devices", len(devices)))...)
+	results := config.PushToDevices(devices, pushRequest, appConfig.Load().GetMaxConcurrentPushes())
+	for _, result := range results {
+		if result.Err != nil {
+			logger.Error("Failed to send", result.LogFields()...)
+			savePendingPush(result.Device, pushRequest, result.Err)
+			continue
+		}
+		logger.Info("Successfully sent", result.LogFields()...)
+	}
+	logger.Info("Finished sending", zap.Int("devices", len(results)), zap.Int("failed", len(results.Failures())))
+	recordPush(pushRequest, results)
+	recordPushMetrics(results)
+	return results
+}
+
+// pushMessageFromClipboardToDevices sends the clipboard content to all devices concurrently,
+// and shows a single notification summarizing the results.
+// profile is applied to the push request if it is not nil.
+func pushMessageFromClipboardToDevices(devices []*config.Device, profile *config.Profile) {
+	pushRequest, err := newPushRequestFromClipboard(profile)
+	if err != nil {
+		logger.Warn("Failed to read the clipboard", zap.Int("devices", len(devices)), zap.Error(err))
+		notifyClipboardError(err)
+		return
+	}
+	devices = guardSecrets(devices, pushRequest, true).Allowed
+	if len(devices) == 0 {
+		return
+	}
+	results := pushToDevices(devices, pushRequest)
+	summary := results.Summary()
+	if len(results.Failures()) > 0 {
+		_ = zenity.Notify(summary, zenity.ErrorIcon)
 	} else {
-		if appConfig.IsDefaultDeviceExist() {
-			sendToDefaultDeviceMenuItem := systray.AddMenuItem("Send to default device", "Send to default device")
-			go func() {
-				for {
-					select {
-					case <-sendToDefaultDeviceMenuItem.ClickedCh:
-						defaultDevice := appConfig.GetDefaultDevice()
-						pushMessageFromClipboard(defaultDevice)
-					}
+		_ = zenity.Notify(summary, zenity.InfoIcon)
+	}
+}
+
+// pushMenu is the menu items that send the clipboard content, they are
+// updated in place with updatePushMenu when the config is reloaded.
+type pushMenu struct {
+	noDevice      *systray.MenuItem
+	sendToDefault *systray.MenuItem
+	sendToAll     *systray.MenuItem
+	sendToDevices *systray.MenuItem
+	devices       *menuSlots
+	sendToGroup   *systray.MenuItem
+	groups        *menuSlots
+	sendAs        *systray.MenuItem
+	profiles      *menuSlots
+	// profileMenus is the sub menus of the profiles, indexed by the slot index in profiles.
+	profileMenus []*profileMenu
+}
+
+// profileMenu is the sub menu of a profile in the "Send as..." menu.
+type profileMenu struct {
+	sendToDefault *systray.MenuItem
+	devices       *menuSlots
+}
+
+var appPushMenu *pushMenu
+
+// deviceAt returns the device at index in appConfig.Devices,
+// or nil if the devices have been changed since the menu was built.
+func deviceAt(index int) *config.Device {
+	devices := appConfig.Load().Devices
+	if index < len(devices) {
+		return devices[index]
+	}
+	return nil
+}
+
+// groupAt returns the group at index in appConfig.Groups, or nil if it does not exist.
+func groupAt(index int) *config.Group {
+	groups := appConfig.Load().Groups
+	if index < len(groups) {
+		return groups[index]
+	}
+	return nil
+}
+
+// profileAt returns the profile at index in appConfig.Profiles, or nil if it does not exist.
+func profileAt(index int) *config.Profile {
+	profiles := appConfig.Load().Profiles
+	if index < len(profiles) {
+		return profiles[index]
+	}
+	return nil
+}
+
+func addPushMenuItems() {
+	pm := &pushMenu{
+		noDevice:      systray.AddMenuItem("No device configured", "No device configured"),
+		sendToDefault: systray.AddMenuItem("Send to default device", "Send to default device"),
+		sendToAll:     systray.AddMenuItem("Send to all devices", "Send to all devices"),
+		sendToDevices: systray.AddMenuItem("Send to devices...", "Send to devices..."),
+		sendToGroup:   systray.AddMenuItem("Send to group...", "Send to group..."),
+		sendAs:        systray.AddMenuItem("Send as...", "Send as..."),
+	}
+	pm.noDevice.Disable()
+	go func() {
+		for {
+			select {
+			case <-pm.sendToDefault.ClickedCh:
+				if defaultDevice := appConfig.Load().GetDefaultDevice(); defaultDevice != nil {
+					pushMessageFromClipboard(defaultDevice, nil)
 				}
-			}()
+			case <-pm.sendToAll.ClickedCh:
+				pushMessageFromClipboardToDevices(appConfig.Load().Devices, nil)
+			}
 		}
-
-		sendToAllDevicesMenuItem := systray.AddMenuItem("Send to all devices", "Send to all devices")
+	}()
+	pm.devices = newMenuSlots(pm.sendToDevices, func(item *systray.MenuItem, index int) {
 		go func() {
-			for {
-				select {
-				case <-sendToAllDevicesMenuItem.ClickedCh:
-					for i := 0; i < len(appConfig.Devices); i++ {
-						device := appConfig.Devices[i]
-						pushMessageFromClipboard(device)
-					}
+			for range item.ClickedCh {
+				if device := deviceAt(index); device != nil {
+					pushMessageFromClipboard(device, nil)
+				}
+			}
+		}()
+	})
+	pm.groups = newMenuSlots(pm.sendToGroup, func(item *systray.MenuItem, index int) {
+		go func() {
+			for range item.ClickedCh {
+				if group := groupAt(index); group != nil {
+					pushMessageFromClipboardToDevices(group.GetDevices(), nil)
 				}
 			}
 		}()
+	})
+	pm.profiles = newMenuSlots(pm.sendAs, pm.addProfileMenu)
+	appPushMenu = pm
+	updatePushMenu()
+}
 
-		sendToDeviceMenuItem := systray.AddMenuItem("Send to devices...", "Send to devices...")
-		for i := 0; i < len(appConfig.Devices); i++ {
-			device := appConfig.Devices[i]
-			subMenuItem := sendToDeviceMenuItem.AddSubMenuItem(device.Name, device.Name)
-			go func() {
-				for range subMenuItem.ClickedCh {
-					pushMessageFromClipboard(device)
+// addProfileMenu adds the sub menu of the profile at index, which lists the devices to send to.
+func (pm *pushMenu) addProfileMenu(item *systray.MenuItem, index int) {
+	profileMenuItems := &profileMenu{
+		sendToDefault: item.AddSubMenuItem("Default device", "Send to default device"),
+	}
+	sendToAll := item.AddSubMenuItem("All devices", "Send to all devices")
+	go func() {
+		for {
+			select {
+			case <-profileMenuItems.sendToDefault.ClickedCh:
+				defaultDevice, profile := appConfig.Load().GetDefaultDevice(), profileAt(index)
+				if defaultDevice != nil && profile != nil {
+					pushMessageFromClipboard(defaultDevice, profile)
+				}
+			case <-sendToAll.ClickedCh:
+				if profile := profileAt(index); profile != nil {
+					pushMessageFromClipboardToDevices(appConfig.Load().Devices, profile)
 				}
-			}()
+			}
 		}
+	}()
+	profileMenuItems.devices = newMenuSlots(item, func(deviceItem *systray.MenuItem, deviceIndex int) {
+		go func() {
+			for range deviceItem.ClickedCh {
+				device, profile := deviceAt(deviceIndex), profileAt(index)
+				if device != nil && profile != nil {
+					pushMessageFromClipboard(device, profile)
+				}
+			}
+		}()
+	})
+	pm.profileMenus = append(pm.profileMenus, profileMenuItems)
+}
+
+// updatePushMenu updates the push menu items to match the devices, groups and profiles in appConfig.
+func updatePushMenu() {
+	currentConfig := appConfig.Load()
+	pm := appPushMenu
+	if pm == nil {
+		return
+	}
+	deviceNames := make([]string, len(currentConfig.Devices))
+	for i, device := range currentConfig.Devices {
+		deviceNames[i] = device.Name
+	}
+	hasDevice := len(deviceNames) > 0
+	setMenuItemVisible(pm.noDevice, !hasDevice)
+	setMenuItemVisible(pm.sendToDefault, currentConfig.IsDefaultDeviceExist())
+	setMenuItemVisible(pm.sendToAll, hasDevice)
+	setMenuItemVisible(pm.sendToDevices, hasDevice)
+	pm.devices.update(deviceNames)
+
+	groupNames := make([]string, len(currentConfig.Groups))
+	for i, group := range currentConfig.Groups {
+		groupNames[i] = group.Name
+	}
+	setMenuItemVisible(pm.sendToGroup, len(groupNames) > 0)
+	pm.groups.update(groupNames)
+
+	profileNames := make([]string, len(currentConfig.Profiles))
+	for i, profile := range currentConfig.Profiles {
+		profileNames[i] = profile.Name
+	}
+	setMenuItemVisible(pm.sendAs, hasDevice && len(profileNames) > 0)
+	pm.profiles.update(profileNames)
+	for i := range profileNames {
+		setMenuItemVisible(pm.profileMenus[i].sendToDefault, currentConfig.IsDefaultDeviceExist())
+		pm.profileMenus[i].devices.update(deviceNames)
+	}
+}
+
+func setMenuItemVisible(item *systray.MenuItem, visible bool) {
+	if visible {
+		item.Show()
+	} else {
+		item.Hide()
 	}
 }
 
 func addStartOnBootMenuItem() {
 	barkTrayPath, err := os.Executable()
 	if err != nil {
-		logger.Error(fmt.Sprintf("Failed to get bark tray path: %s", err.Error()))
+		logger.Error("Failed to get bark tray path", zap.Error(err))
 	} else {
 		systray.AddSeparator()
 		app := &autostart.App{
@@ -128,7 +395,15 @@ func onReady() {
 	systray.SetTooltip("Bark Tray")
 
 	addPushMenuItems()
+	addManageDevicesMenuItem()
+	addWatchClipboardMenuItem()
+	addPendingMenuItem()
+	addRecentMenuItem()
+	addStatisticsMenuItem()
 	addStartOnBootMenuItem()
+	addReloadConfigMenuItem()
+	registerHotkeys()
+	watchConfigFile()
 
 	systray.AddSeparator()
 	githubMenuItem := systray.AddMenuItem("Github", "Github")
@@ -147,7 +422,13 @@ func onReady() {
 }
 
 func onExit() {
-	if appConfig != nil && appConfig.EnableLog {
+	currentConfig := appConfig.Load()
+	close(stopCh)
+	stopWatchingConfigFile()
+	hotkeys.UnregisterAll()
+	stopLocalApi()
+	stopClipboardWatcher()
+	if currentConfig != nil && currentConfig.EnableLog {
 		_ = logger.Sync()
 	}
 }
@@ -155,6 +436,14 @@ func onExit() {
 func main() {
 	var err error
 
+	configFlag, args, err := parseConfigFlag(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n%s", err.Error(), cliUsage)
+		os.Exit(exitUsage)
+	}
+	// Any other arguments, e.g. those passed by the OS, start the tray application
+	isCli := isCliCommand(args)
+
 	executablePath, err := util.GetExecutablePath()
 	if err != nil {
 		_ = zenity.Error(
@@ -163,18 +452,43 @@ func main() {
 			zenity.OKLabel("OK"),
 		)
 	}
-	configFilePath := filepath.Join(executablePath, configFileName)
+	paths, err := config.ResolvePaths(configFlag, executablePath)
+	if err != nil {
+		if isCli {
+			fmt.Fprintf(os.Stderr, "Failed to find config file: %s\n", err.Error())
+			os.Exit(exitConfigError)
+		}
+		_ = zenity.Error(
+			"Failed to find config file: "+err.Error(),
+			zenity.Title("Bark Tray"),
+			zenity.OKLabel("OK"),
+		)
+		return
+	}
+	configFilePath := paths.ConfigFilePath
+	// The data directory is created beforehand for the log file and the outbox
+	if err = os.MkdirAll(paths.DataDir, 0755); err != nil && !isCli {
+		_ = zenity.Error(
+			"Failed to create data directory: "+err.Error(),
+			zenity.Title("Bark Tray"),
+			zenity.OKLabel("OK"),
+		)
+	}
+
+	if isCli {
+		os.Exit(runCli(configFilePath, paths.DataDir, args))
+	}
 
 	if !util.IsFileExists(configFilePath) {
 		err = config.CreateConfigFileTemplate(configFilePath)
 		if err != nil {
 			_ = zenity.Error(
-				"Failed to create config file template: "+err.Error()+"\nPlease check the write permission of the current directory.",
+				"Failed to create config file template: "+err.Error()+"\nPlease check the write permission of "+filepath.Dir(configFilePath)+".",
 				zenity.Title("Bark Tray"),
 				zenity.OKLabel("OK"),
 			)
 		} else {
-			_ = zenity.Info("No config file found.\nA config file template has been created in the current directory.\nPlease fill in the configuration and restart the application.",
+			_ = zenity.Info("No config file found.\nA config file template has been created at "+configFilePath+".\nPlease fill in the configuration and restart the application.",
 				zenity.Title("Bark Tray"),
 				zenity.OKLabel("OK"),
 			)
@@ -182,7 +496,17 @@ func main() {
 		return
 	}
 
-	appConfig, err = config.LoadConfig(configFilePath)
+	configBackupFilePath, err := config.MigrateConfigFile(configFilePath)
+	if err != nil {
+		_ = zenity.Error(
+			"Failed to upgrade config file: "+err.Error(),
+			zenity.Title("Bark Tray"),
+			zenity.OKLabel("OK"),
+		)
+		return
+	}
+
+	loadedConfig, err := config.LoadConfig(configFilePath)
 	if err != nil {
 		_ = zenity.Error(
 			"Failed to load config file: "+err.Error()+"\nPlease check the config file and restart the application.",
@@ -192,9 +516,11 @@ func main() {
 		return
 	}
 
-	if appConfig.EnableLog {
-		appConfig.LogFilePath = util.ToAbsolutePath(appConfig.LogFilePath, executablePath)
-		err = logger.InitLogger(appConfig.LogFilePath)
+	// An invalid redaction is reported by Validate, the default is used until it is fixed
+	_ = logger.SetRedaction(loadedConfig.LogRedaction)
+	if loadedConfig.EnableLog {
+		loadedConfig.LogFilePath = util.ToAbsolutePath(loadedConfig.LogFilePath, paths.DataDir)
+		err = logger.InitLogger(loadedConfig.LogFilePath, loadedConfig.Log)
 		if err != nil {
 			_ = zenity.Error(
 				"Failed to initialize logger: "+err.Error()+"\nPlease check the log file path.",
@@ -204,7 +530,28 @@ func main() {
 		}
 	}
 
-	appConfig.StripInvalidDevices()
+	if configBackupFilePath != "" {
+		message := fmt.Sprintf("Config file upgraded to %s, the original file is saved as %s", config.CurrentVersion, configBackupFilePath)
+		logger.Info(message)
+		_ = zenity.Notify(message, zenity.InfoIcon)
+	}
+
+	if err = loadedConfig.Validate(); err != nil {
+		logger.Warn("Problems found in the config file:\n" + err.Error())
+		showErrorDialog("Problems found in the config file, the invalid items are ignored:\n\n" + err.Error())
+	}
+
+	appConfigFilePath = configFilePath
+	appDataDir = paths.DataDir
+	if err = resolveAppSecrets(loadedConfig); err != nil {
+		logger.Warn("Failed to resolve device keys:\n" + err.Error())
+		showErrorDialog("Failed to resolve device keys, the devices are ignored:\n\n" + err.Error())
+	}
+
+	loadedConfig.StripInvalidDevices()
+	loadedConfig.StripInvalidProfiles()
+	loadedConfig.StripInvalidGroups()
+	appConfig.Store(loadedConfig)
 
 	err = clipboard.Init()
 	if err != nil {
@@ -217,6 +564,19 @@ func main() {
 		return
 	}
 
-	httpClient.Setup(appConfig.UserAgent, appConfig.Timeout)
+	httpClient.Setup(loadedConfig.UserAgent, loadedConfig.Timeout)
+
+	pushOutbox, err = outbox.Open(filepath.Join(paths.DataDir, outboxFileName), pushOutboxEntry)
+	if err != nil {
+		logger.Error("Failed to open outbox, failed messages will not be retried: " + err.Error())
+	} else {
+		pushOutbox.SetOnRetry(onOutboxEntryRetried)
+		go pushOutbox.Run(stopCh)
+	}
+
+	openPushHistory(paths.DataDir)
+
+	startLocalApi()
+
 	systray.Run(onReady, onExit)
 }
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/config"
+	"github.com/LGiki/bark-tray/pkg/httpClient"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/LGiki/bark-tray/pkg/secret"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// The exit codes of the command-line interface.
+const (
+	exitOk = 0
+	// exitPushFailed means the message failed to be sent to at least one device.
+	exitPushFailed = 1
+	// exitUsage means the command or its arguments are invalid.
+	exitUsage = 2
+	// exitConfigError means the config file cannot be loaded or is invalid, or a device specified does not exist.
+	exitConfigError = 3
+)
+
+const cliUsage = `Usage:
+  bark-tray [--config PATH] push [--device NAME]... [--group NAME]... [--all] [--title TITLE] [--url URL] [--stdin] [BODY...]
+  bark-tray [--config PATH] devices list
+  bark-tray [--config PATH] config check
+  bark-tray [--config PATH] config convert [SOURCE] DESTINATION
+  bark-tray [--config PATH] secret set NAME
+  bark-tray [--config PATH] secret delete NAME
+
+Run bark-tray without a command to start the tray application.
+
+config convert converts SOURCE, defaults to the config file, to the format of DESTINATION,
+the formats are determined by the extensions: .json, .yaml, .yml or .toml.
+
+secret set stores the secret read from stdin as NAME in the secret store, which is referenced
+by "keyring:NAME" in the config file. The OS keyring is used, or the encrypted file if it is not
+available, whose passphrase is read from $BARK_TRAY_PASSPHRASE.
+
+The config file is the first of --config, $BARK_TRAY_CONFIG, bark-tray/config.json
+in the user config directory and config.json next to the executable that is set or exists,
+config.yaml, config.yml and config.toml are also looked up after config.json.
+`
+
+// stringListFlag is a flag that can be specified multiple times.
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// parseConfigFlag extracts the --config flag in front of the command from args,
+// which are the arguments without the program name, and returns the rest of args.
+// The "-psn_..." argument macOS passes to an app opened from Finder is dropped.
+func parseConfigFlag(args []string) (configFlag string, restArgs []string, err error) {
+	for len(args) > 0 {
+		switch arg := args[0]; {
+		case arg == "--config" || arg == "-config":
+			if len(args) < 2 || args[1] == "" {
+				return "", nil, fmt.Errorf("%s requires a path", arg)
+			}
+			configFlag = args[1]
+			args = args[2:]
+		case strings.HasPrefix(arg, "-psn_"):
+			// The process serial number passed by macOS when the app is opened from Finder
+			args = args[1:]
+		case strings.HasPrefix(arg, "--config=") || strings.HasPrefix(arg, "-config="):
+			configFlag = arg[strings.Index(arg, "=")+1:]
+			if configFlag == "" {
+				return "", nil, fmt.Errorf("%s requires a path", arg[:strings.Index(arg, "=")])
+			}
+			args = args[1:]
+		default:
+			return configFlag, args, nil
+		}
+	}
+	return configFlag, args, nil
+}
+
+// cliCommands is the commands of the command-line interface, Bark Tray starts
+// as the tray application if it is run without any of them.
+var cliCommands = map[string]bool{
+	"push":    true,
+	"devices": true,
+	"config":  true,
+	"secret":  true,
+	"help":    true,
+	"-h":      true,
+	"-help":   true,
+	"--help":  true,
+}
+
+// isCliCommand reports whether args, which are the arguments after the --config flag,
+// start with a command of the command-line interface.
+func isCliCommand(args []string) bool {
+	return len(args) > 0 && cliCommands[args[0]]
+}
+
+// runCli runs the command-line interface with args, which are the arguments
+// without the program name, and returns the exit code.
+func runCli(configFilePath string, dataDir string, args []string) int {
+	switch args[0] {
+	case "push":
+		return runPushCommand(configFilePath, dataDir, args[1:])
+	case "devices":
+		if len(args) != 2 || args[1] != "list" {
+			fmt.Fprint(os.Stderr, cliUsage)
+			return exitUsage
+		}
+		return runDevicesListCommand(configFilePath, dataDir)
+	case "config":
+		switch {
+		case len(args) == 2 && args[1] == "check":
+			return runConfigCheckCommand(configFilePath, dataDir)
+		case len(args) == 3 && args[1] == "convert":
+			return runConfigConvertCommand(configFilePath, args[2])
+		case len(args) == 4 && args[1] == "convert":
+			return runConfigConvertCommand(args[2], args[3])
+		}
+		fmt.Fprint(os.Stderr, cliUsage)
+		return exitUsage
+	case "secret":
+		switch {
+		case len(args) == 3 && args[1] == "set":
+			return runSecretSetCommand(configFilePath, dataDir, args[2])
+		case len(args) == 3 && args[1] == "delete":
+			return runSecretDeleteCommand(configFilePath, dataDir, args[2])
+		}
+		fmt.Fprint(os.Stderr, cliUsage)
+		return exitUsage
+	case "help", "-h", "-help", "--help":
+		fmt.Print(cliUsage)
+		return exitOk
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command: %s\n%s", args[0], cliUsage)
+	return exitUsage
+}
+
+// loadCliConfig loads the config file for the command-line interface,
+// the errors are printed to stderr instead of being shown in dialogs.
+func loadCliConfig(configFilePath string, dataDir string) (*config.Config, bool) {
+	cliConfig, err := config.LoadConfig(configFilePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config file: %s\n", err.Error())
+		return nil, false
+	}
+	_ = logger.SetRedaction(cliConfig.LogRedaction)
+	if cliConfig.EnableLog {
+		cliConfig.LogFilePath = util.ToAbsolutePath(cliConfig.LogFilePath, dataDir)
+		if err = logger.InitLogger(cliConfig.LogFilePath, cliConfig.Log); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize logger: %s\n", err.Error())
+		}
+	}
+	resolver := newSecretResolver(cliConfig.SecretStore, configFilePath, dataDir, envPassphrase)
+	if err = cliConfig.ResolveSecrets(resolver); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to resolve device keys, the devices are ignored:\n%s\n", err.Error())
+	}
+	cliConfig.StripInvalidDevices()
+	cliConfig.StripInvalidGroups()
+	return cliConfig, true
+}
+
+func runPushCommand(configFilePath string, dataDir string, args []string) int {
+	flagSet := flag.NewFlagSet("push", flag.ContinueOnError)
+	flagSet.SetOutput(os.Stderr)
+	var deviceNames stringListFlag
+	flagSet.Var(&deviceNames, "device", "name of the device to send to, can be specified multiple times")
+	var groupNames stringListFlag
+	flagSet.Var(&groupNames, "group", "name of the group to send to, can be specified multiple times")
+	all := flagSet.Bool("all", false, "send to all devices")
+	title := flagSet.String("title", "", "title of the notification")
+	messageUrl := flagSet.String("url", "", "url opened when the notification is clicked, defaults to the first url in the body")
+	readStdin := flagSet.Bool("stdin", false, "read the body from stdin")
+	if err := flagSet.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	var body string
+	if *readStdin {
+		if flagSet.NArg() > 0 {
+			fmt.Fprintln(os.Stderr, "The body cannot be specified with --stdin")
+			return exitUsage
+		}
+		stdinBytes, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read stdin: %s\n", err.Error())
+			return exitUsage
+		}
+		body = string(stdinBytes)
+	} else {
+		body = strings.Join(flagSet.Args(), " ")
+	}
+	body = strings.TrimSpace(body)
+	if body == "" {
+		fmt.Fprintln(os.Stderr, "The body is empty")
+		return exitUsage
+	}
+	if *messageUrl != "" && !util.IsValidHttpUrl(*messageUrl) {
+		fmt.Fprintf(os.Stderr, "Invalid url: %s\n", *messageUrl)
+		return exitUsage
+	}
+
+	cliConfig, ok := loadCliConfig(configFilePath, dataDir)
+	if !ok {
+		return exitConfigError
+	}
+	var devices []*config.Device
+	switch {
+	case *all && (len(deviceNames) > 0 || len(groupNames) > 0):
+		fmt.Fprintln(os.Stderr, "--device and --group cannot be specified with --all")
+		return exitUsage
+	case *all:
+		devices = cliConfig.Devices
+	case len(deviceNames) > 0 || len(groupNames) > 0:
+		added := make(map[*config.Device]bool)
+		addDevice := func(device *config.Device) {
+			if !added[device] {
+				devices = append(devices, device)
+				added[device] = true
+			}
+		}
+		for _, deviceName := range deviceNames {
+			device := cliConfig.GetDevice(deviceName)
+			if device == nil {
+				fmt.Fprintf(os.Stderr, "Device not found: %s\n", deviceName)
+				return exitConfigError
+			}
+			addDevice(device)
+		}
+		for _, groupName := range groupNames {
+			group := cliConfig.GetGroup(groupName)
+			if group == nil {
+				fmt.Fprintf(os.Stderr, "Group not found: %s\n", groupName)
+				return exitConfigError
+			}
+			for _, device := range group.GetDevices() {
+				addDevice(device)
+			}
+		}
+	default:
+		defaultDevice := cliConfig.GetDefaultDevice()
+		if defaultDevice == nil {
+			fmt.Fprintln(os.Stderr, "No device specified and there is no default device")
+			return exitConfigError
+		}
+		devices = append(devices, defaultDevice)
+	}
+	if len(devices) == 0 {
+		fmt.Fprintln(os.Stderr, "There is no device in the config file")
+		return exitConfigError
+	}
+
+	httpClient.Setup(cliConfig.UserAgent, cliConfig.Timeout)
+	pushRequest := config.NewTextPushRequest(body)
+	pushRequest.Title = *title
+	if *messageUrl != "" {
+		pushRequest.Url = *messageUrl
+	}
+	check := guardSecrets(devices, pushRequest, false)
+	for _, device := range check.Blocked {
+		fmt.Fprintf(os.Stderr, "Not sent to '%s': the message contains %s\n", device.Name, secretKindsText(check.Kinds))
+	}
+	devices = check.Allowed
+	logger.Info("Start sending from the command line", append(logger.BodyFields(pushRequest.Body), zap.Int("devices", len(devices)))...)
+	results := config.PushToDevices(devices, pushRequest, cliConfig.GetMaxConcurrentPushes())
+	for _, result := range results {
+		if result.Err != nil {
+			logger.Error("Failed to send", result.LogFields()...)
+			fmt.Fprintf(os.Stderr, "Failed to send to '%s': %s\n", result.Device.Name, result.Err.Error())
+			continue
+		}
+		logger.Info("Successfully sent", result.LogFields()...)
+		fmt.Printf("Successfully sent to '%s'\n", result.Device.Name)
+	}
+	_ = logger.Sync()
+	if len(results.Failures()) > 0 || len(check.Blocked) > 0 {
+		return exitPushFailed
+	}
+	return exitOk
+}
+
+func runDevicesListCommand(configFilePath string, dataDir string) int {
+	cliConfig, ok := loadCliConfig(configFilePath, dataDir)
+	if !ok {
+		return exitConfigError
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tSERVER\tDEFAULT")
+	for _, device := range cliConfig.Devices {
+		isDefault := ""
+		if device.IsDefault {
+			isDefault = "*"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", device.Name, device.BarkBaseUrl, isDefault)
+	}
+	_ = writer.Flush()
+	return exitOk
+}
+
+func runConfigCheckCommand(configFilePath string, dataDir string) int {
+	checkConfig, err := config.LoadConfig(configFilePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config file: %s\n", err.Error())
+		return exitConfigError
+	}
+	if checkConfig.EnableLog {
+		checkConfig.LogFilePath = util.ToAbsolutePath(checkConfig.LogFilePath, dataDir)
+	}
+	if err = checkConfig.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Problems found in %s:\n%s\n", configFilePath, err.Error())
+		return exitConfigError
+	}
+	fmt.Printf("%s is valid\n", configFilePath)
+	return exitOk
+}
+
+func runConfigConvertCommand(sourcePath string, destinationPath string) int {
+	if err := config.ConvertConfigFile(sourcePath, destinationPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to convert config file: %s\n", err.Error())
+		return exitConfigError
+	}
+	fmt.Printf("%s is converted to %s\n", sourcePath, destinationPath)
+	return exitOk
+}
+
+// openCliSecretStore opens the secret store set in the config file, or the default one if the config file does not exist.
+func openCliSecretStore(configFilePath string, dataDir string) (secret.Store, bool) {
+	var options *secret.Options
+	if util.IsFileExists(configFilePath) {
+		cliConfig, err := config.LoadConfig(configFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load config file: %s\n", err.Error())
+			return nil, false
+		}
+		if cliConfig.SecretStore != nil {
+			if err = cliConfig.SecretStore.Validate(); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid secret store: %s\n", err.Error())
+				return nil, false
+			}
+		}
+		options = cliConfig.SecretStore
+	}
+	return secret.OpenStore(options, dataDir, envPassphrase), true
+}
+
+func runSecretSetCommand(configFilePath string, dataDir string, name string) int {
+	store, ok := openCliSecretStore(configFilePath, dataDir)
+	if !ok {
+		return exitConfigError
+	}
+	secretBytes, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read stdin: %s\n", err.Error())
+		return exitUsage
+	}
+	secretValue := strings.TrimSpace(string(secretBytes))
+	if secretValue == "" {
+		fmt.Fprintln(os.Stderr, "The secret is empty")
+		return exitUsage
+	}
+	if err = store.Set(name, secretValue); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to store secret: %s\n", err.Error())
+		return exitConfigError
+	}
+	fmt.Printf("Secret '%s' is stored, use \"%s%s\" as the device key\n", name, secret.KeyringPrefix, name)
+	return exitOk
+}
+
+func runSecretDeleteCommand(configFilePath string, dataDir string, name string) int {
+	store, ok := openCliSecretStore(configFilePath, dataDir)
+	if !ok {
+		return exitConfigError
+	}
+	if err := store.Delete(name); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to delete secret: %s\n", err.Error())
+		return exitConfigError
+	}
+	fmt.Printf("Secret '%s' is deleted\n", name)
+	return exitOk
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/config"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/LGiki/bark-tray/pkg/qrcode"
+	"github.com/LGiki/bark-tray/pkg/secret"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"github.com/getlantern/systray"
+	"github.com/ncruces/zenity"
+	"go.uber.org/zap"
+	"golang.design/x/clipboard"
+	"strings"
+)
+
+const (
+	defaultBarkBaseUrl = "https://api.day.app"
+	testPushMessage    = "This is a test message from Bark Tray."
+)
+
+// addManageDevicesMenuItem adds the "Manage devices" menu, the changes are
+// written to the config file and applied by reloading it.
+func addManageDevicesMenuItem() {
+	manageDevicesMenuItem := systray.AddMenuItem("Manage devices", "Manage devices")
+	addDeviceMenuItem := manageDevicesMenuItem.AddSubMenuItem("Add device...", "Add device")
+	editDeviceMenuItem := manageDevicesMenuItem.AddSubMenuItem("Edit device...", "Edit device")
+	removeDeviceMenuItem := manageDevicesMenuItem.AddSubMenuItem("Remove device...", "Remove device")
+	setDefaultDeviceMenuItem := manageDevicesMenuItem.AddSubMenuItem("Set as default...", "Set the default device")
+	importDeviceMenuItem := manageDevicesMenuItem.AddSubMenuItem("Import device from clipboard", "Import the Bark URL or QR code in the clipboard")
+	go func() {
+		for {
+			var err error
+			select {
+			case <-addDeviceMenuItem.ClickedCh:
+				err = addDeviceWithDialog()
+			case <-editDeviceMenuItem.ClickedCh:
+				err = editDeviceWithDialog()
+			case <-removeDeviceMenuItem.ClickedCh:
+				err = removeDeviceWithDialog()
+			case <-setDefaultDeviceMenuItem.ClickedCh:
+				err = setDefaultDeviceWithDialog()
+			case <-importDeviceMenuItem.ClickedCh:
+				err = importDeviceFromClipboard()
+			}
+			if err != nil && err != zenity.ErrCanceled {
+				logger.Error("Failed to update devices: " + err.Error())
+				showErrorDialog("Failed to update devices: " + err.Error())
+			}
+		}
+	}()
+}
+
+func showErrorDialog(message string) {
+	_ = zenity.Error(message, zenity.Title("Bark Tray"), zenity.OKLabel("OK"))
+}
+
+// applyDevicesChange reloads the config file after the devices in it are changed.
+func applyDevicesChange(logMessage string) error {
+	logger.Info(logMessage)
+	if err := reloadConfig(); err != nil {
+		return fmt.Errorf("the config file is saved but failed to be reloaded: %s", err.Error())
+	}
+	_ = zenity.Notify(logMessage, zenity.InfoIcon)
+	return nil
+}
+
+func addDeviceWithDialog() error {
+	device, err := promptDevice("Add device", &config.Device{BarkBaseUrl: defaultBarkBaseUrl}, "")
+	if err != nil {
+		return err
+	}
+	if err = config.AddDevice(appConfigFilePath, device); err != nil {
+		return err
+	}
+	return applyDevicesChange(fmt.Sprintf("Device '%s' added", device.Name))
+}
+
+func editDeviceWithDialog() error {
+	device, err := selectDevice("Edit device", "Select the device to edit:")
+	if err != nil {
+		return err
+	}
+	editedDevice, err := promptDevice("Edit device", device, device.Name)
+	if err != nil {
+		return err
+	}
+	if err = config.UpdateDevice(appConfigFilePath, device.Name, editedDevice); err != nil {
+		return err
+	}
+	return applyDevicesChange(fmt.Sprintf("Device '%s' updated", editedDevice.Name))
+}
+
+func removeDeviceWithDialog() error {
+	device, err := selectDevice("Remove device", "Select the device to remove:")
+	if err != nil {
+		return err
+	}
+	err = zenity.Question(
+		fmt.Sprintf("Are you sure you want to remove device '%s'?", device.Name),
+		zenity.Title("Remove device"),
+		zenity.OKLabel("Remove"),
+		zenity.CancelLabel("Cancel"),
+	)
+	if err != nil {
+		return err
+	}
+	if err = config.RemoveDevice(appConfigFilePath, device.Name); err != nil {
+		return err
+	}
+	return applyDevicesChange(fmt.Sprintf("Device '%s' removed", device.Name))
+}
+
+func setDefaultDeviceWithDialog() error {
+	device, err := selectDevice("Set as default", "Select the default device:")
+	if err != nil {
+		return err
+	}
+	if err = config.SetDefaultDevice(appConfigFilePath, device.Name); err != nil {
+		return err
+	}
+	return applyDevicesChange(fmt.Sprintf("Device '%s' is now the default device", device.Name))
+}
+
+// importDeviceFromClipboard adds the device of the Bark url in the clipboard, which is
+// either a text or a QR code image, e.g. the url copied from the Bark App homepage.
+func importDeviceFromClipboard() error {
+	barkUrl, err := readBarkUrlFromClipboard()
+	if err != nil {
+		return err
+	}
+	barkBaseUrl, key, err := config.ParseBarkUrl(barkUrl)
+	if err != nil {
+		return err
+	}
+	for _, device := range appConfig.Load().Devices {
+		if resolvedDeviceKey(device) == key && strings.TrimSuffix(device.BarkBaseUrl, "/") == barkBaseUrl {
+			return fmt.Errorf("the device already exists as '%s'", device.Name)
+		}
+	}
+	device, err := promptDevice("Import device", &config.Device{BarkBaseUrl: barkBaseUrl, Key: key}, "")
+	if err != nil {
+		return err
+	}
+	if err = config.AddDevice(appConfigFilePath, device); err != nil {
+		return err
+	}
+	return applyDevicesChange(fmt.Sprintf("Device '%s' imported", device.Name))
+}
+
+// resolvedDeviceKey returns the key of device with its secret reference resolved,
+// or an empty string if the reference cannot be resolved.
+func resolvedDeviceKey(device *config.Device) string {
+	if !secret.IsReference(device.Key) {
+		return device.Key
+	}
+	key, err := appSecretResolver.Resolve(device.Key)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// readBarkUrlFromClipboard returns the text in the clipboard,
+// or the text of the QR code if there is an image in the clipboard.
+func readBarkUrlFromClipboard() (string, error) {
+	if clipboardText, ok := readClipboardText(); ok && clipboardText != "" {
+		return clipboardText, nil
+	}
+	clipboardImageBytes := clipboard.Read(clipboard.FmtImage)
+	if clipboardImageBytes == nil {
+		return "", errors.New("there is no Bark URL or QR code in the clipboard")
+	}
+	text, err := qrcode.Decode(clipboardImageBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode the QR code in the clipboard: %s", err.Error())
+	}
+	return text, nil
+}
+
+// selectDevice asks the user to select one of the devices in appConfig.
+func selectDevice(title string, text string) (*config.Device, error) {
+	currentConfig := appConfig.Load()
+	devices := currentConfig.Devices
+	if len(devices) == 0 {
+		return nil, errors.New("there is no device")
+	}
+	deviceNames := make([]string, len(devices))
+	for i, device := range devices {
+		deviceNames[i] = device.Name
+	}
+	deviceName, err := zenity.List(text, deviceNames, zenity.Title(title), zenity.DisallowEmpty())
+	if err != nil {
+		return nil, err
+	}
+	device := currentConfig.GetDevice(deviceName)
+	if device == nil {
+		return nil, fmt.Errorf("device '%s' not found", deviceName)
+	}
+	return device, nil
+}
+
+// promptDevice asks the user for the name, the url and the key of a device with
+// the values of device as the defaults, and sends a test message to it.
+// currentName is the name of the device being edited, or empty if a new device is added.
+func promptDevice(title string, device *config.Device, currentName string) (*config.Device, error) {
+	name, err := promptEntry(title, "Device name:", device.Name, func(name string) error {
+		if name == "" {
+			return errors.New("the device name is empty")
+		}
+		if name != currentName && appConfig.Load().GetDevice(name) != nil {
+			return fmt.Errorf("device '%s' already exists", name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	barkBaseUrl, err := promptEntry(title, "Bark server URL:", device.BarkBaseUrl, func(barkBaseUrl string) error {
+		if !util.IsValidHttpUrl(barkBaseUrl) {
+			return fmt.Errorf("'%s' is not a valid url", barkBaseUrl)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	barkBaseUrl, err = util.StripQueryParamFromUrl(barkBaseUrl)
+	if err != nil {
+		return nil, err
+	}
+	key, err := promptEntry(title, "Device key, or a secret reference like keyring:NAME, env:VAR or file:PATH:", device.ConfigKey(), func(key string) error {
+		if key == "" {
+			return errors.New("the device key is empty")
+		}
+		// The user may enter the passphrase of the encrypted file again after a wrong one
+		appSecretResolver.Retry()
+		_, err := appSecretResolver.Resolve(key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	isDefault := device.IsDefault
+	if !isDefault {
+		err = zenity.Question(
+			fmt.Sprintf("Set '%s' as the default device?", name),
+			zenity.Title(title),
+			zenity.OKLabel("Yes"),
+			zenity.CancelLabel("No"),
+		)
+		isDefault = err == nil
+	}
+
+	newDevice := *device
+	newDevice.Name = name
+	newDevice.BarkBaseUrl = barkBaseUrl
+	newDevice.IsDefault = isDefault
+	if err = newDevice.SetKey(key, appSecretResolver); err != nil {
+		return nil, err
+	}
+	if err = newDevice.Push(config.NewTextPushRequest(testPushMessage)); err != nil {
+		logger.Warn("Failed to send the test message", zap.String("device", name), zap.Error(err))
+		err = zenity.Question(
+			fmt.Sprintf("Failed to send the test message to '%s': %s\nSave the device anyway?", name, err.Error()),
+			zenity.Title(title),
+			zenity.OKLabel("Save"),
+			zenity.CancelLabel("Cancel"),
+			zenity.WarningIcon,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &newDevice, nil
+}
+
+// promptEntry asks the user for a value until it passes validate or the user cancels.
+func promptEntry(title string, text string, defaultValue string, validate func(value string) error) (string, error) {
+	for {
+		value, err := zenity.Entry(text, zenity.Title(title), zenity.EntryText(defaultValue))
+		if err != nil {
+			return "", err
+		}
+		value = strings.TrimSpace(value)
+		if err = validate(value); err != nil {
+			showErrorDialog(err.Error())
+			defaultValue = value
+			continue
+		}
+		return value, nil
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/config"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/LGiki/bark-tray/pkg/sensitive"
+	"github.com/ncruces/zenity"
+	"go.uber.org/zap"
+	"strings"
+)
+
+// guardSecrets inspects pushRequest for secrets before it is sent to devices, and decides
+// the devices it can be sent to by their secret guards. If interactive is true, the user is
+// notified of the blocked devices and asked to confirm sending to the devices whose secret
+// guard is confirm, otherwise those devices are blocked too.
+// The returned check has no Unconfirmed devices.
+func guardSecrets(devices []*config.Device, pushRequest *bark.PushRequest, interactive bool) *config.SecretCheck {
+	check := config.CheckSecrets(devices, pushRequest)
+	if len(check.Kinds) == 0 {
+		return check
+	}
+	kinds := secretKindsText(check.Kinds)
+	if len(check.Blocked) > 0 {
+		logger.Warn("Not sending the message containing secrets", zap.String("devices", deviceNamesText(check.Blocked)), zap.String("secrets", kinds))
+		if interactive {
+			_ = zenity.Notify(fmt.Sprintf("Not sent to %s, the message contains %s", deviceNamesText(check.Blocked), kinds), zenity.WarningIcon)
+		}
+	}
+	if len(check.Unconfirmed) > 0 {
+		if interactive && confirmSendingSecrets(check.Unconfirmed, kinds) {
+			logger.Info("Sending the message containing secrets is confirmed", zap.String("devices", deviceNamesText(check.Unconfirmed)), zap.String("secrets", kinds))
+			check.Allowed = append(check.Allowed, check.Unconfirmed...)
+		} else {
+			logger.Warn("Not sending the message containing secrets without confirmation", zap.String("devices", deviceNamesText(check.Unconfirmed)), zap.String("secrets", kinds))
+			check.Blocked = append(check.Blocked, check.Unconfirmed...)
+		}
+		check.Unconfirmed = nil
+	}
+	return check
+}
+
+// confirmSendingSecrets asks the user whether to send the message containing kinds of secrets to devices.
+func confirmSendingSecrets(devices []*config.Device, kinds string) bool {
+	err := zenity.Question(
+		fmt.Sprintf("The message contains %s.\n\nSend it to %s anyway?", kinds, deviceNamesText(devices)),
+		zenity.Title("Bark Tray"),
+		zenity.WarningIcon,
+		zenity.OKLabel("Send"),
+		zenity.CancelLabel("Cancel"),
+	)
+	return err == nil
+}
+
+// secretKindsText returns the kinds of secrets for messages, e.g. "a JWT, an API key".
+func secretKindsText(kinds []sensitive.Kind) string {
+	texts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		article := "a "
+		if strings.ContainsRune("AEIOU", rune(kind[0])) {
+			article = "an "
+		}
+		texts[i] = article + string(kind)
+	}
+	return strings.Join(texts, ", ")
+}
+
+// deviceNamesText returns the names of devices for messages, e.g. "'iPhone', 'iPad'".
+func deviceNamesText(devices []*config.Device) string {
+	names := make([]string, len(devices))
+	for i, device := range devices {
+		names[i] = "'" + device.Name + "'"
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/config"
+	"github.com/LGiki/bark-tray/pkg/history"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/getlantern/systray"
+	"github.com/ncruces/zenity"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	historyFileName = "history.json"
+	// maxRecentMenuEntries is the maximum number of entries shown in the "Recent" menu,
+	// the newest entries are shown first.
+	maxRecentMenuEntries = 10
+	// maxRecentMenuTitleLength is the maximum length of the message shown in the "Recent" menu.
+	maxRecentMenuTitleLength = 30
+)
+
+var (
+	// pushHistory is nil if the history file cannot be opened.
+	pushHistory *history.History
+	// historyPolicy is the policy of the history config in appConfig.
+	historyPolicy *history.Policy
+	appRecentMenu *recentMenu
+)
+
+// openPushHistory opens the history in dataDir with the history config in appConfig,
+// the recorded entries are removed if the history is turned off.
+func openPushHistory(dataDir string) {
+	currentConfig := appConfig.Load()
+	policy, err := history.NewPolicy(currentConfig.History)
+	if err != nil {
+		// The config has been validated, fall back to the defaults
+		policy, _ = history.NewPolicy(nil)
+	}
+	historyPolicy = policy
+	pushHistory, err = history.Open(filepath.Join(dataDir, historyFileName), currentConfig.History.GetMaxEntries())
+	if err != nil {
+		logger.Error("Failed to open the history, sent messages will not be recorded: " + err.Error())
+		return
+	}
+	if !policy.IsEnabled() && len(pushHistory.Entries()) > 0 {
+		if err = pushHistory.Clear(); err != nil {
+			logger.Error("Failed to clear the history: " + err.Error())
+		}
+	}
+}
+
+// reloadPushHistory applies the history config in appConfig after it is reloaded.
+func reloadPushHistory() {
+	currentConfig := appConfig.Load()
+	policy, err := history.NewPolicy(currentConfig.History)
+	if err != nil {
+		return
+	}
+	historyPolicy = policy
+	if pushHistory == nil {
+		return
+	}
+	if err = pushHistory.SetMaxEntries(currentConfig.History.GetMaxEntries()); err != nil {
+		logger.Error("Failed to save the history: " + err.Error())
+	}
+	if !policy.IsEnabled() && len(pushHistory.Entries()) > 0 {
+		if err = pushHistory.Clear(); err != nil {
+			logger.Error("Failed to clear the history: " + err.Error())
+		}
+	}
+	if appRecentMenu != nil {
+		appRecentMenu.refresh()
+	}
+}
+
+// recordPush adds pushRequest sent with results to the history.
+func recordPush(pushRequest *bark.PushRequest, results config.PushResults) {
+	policy := historyPolicy
+	if pushHistory == nil || policy == nil || !policy.IsEnabled() {
+		return
+	}
+	deliveries := make([]history.Delivery, len(results))
+	for i, result := range results {
+		deliveries[i] = history.Delivery{DeviceName: result.Device.Name}
+		if result.Err != nil {
+			deliveries[i].Error = result.Err.Error()
+		} else if result.Response != nil {
+			deliveries[i].Response = result.Response.Message
+		}
+	}
+	if err := pushHistory.Add(policy.NewEntry(pushRequest, deliveries)); err != nil {
+		logger.Error("Failed to save the history: " + err.Error())
+	}
+}
+
+// historyEntryTitle returns the title of entry in the "Recent" menu, e.g. "15:04 iPhone: hello".
+func historyEntryTitle(entry *history.Entry) string {
+	var target string
+	if len(entry.Deliveries) == 1 {
+		target = entry.Deliveries[0].DeviceName
+	} else {
+		target = fmt.Sprintf("%d devices", len(entry.Deliveries))
+	}
+	title := fmt.Sprintf("%s %s: %s", entry.SentAt.Format("15:04"), target, truncateText(entry.Summary, maxRecentMenuTitleLength))
+	if failedCount := entry.FailedCount(); failedCount > 0 {
+		title += fmt.Sprintf(" (%d failed)", failedCount)
+	}
+	return title
+}
+
+type recentMenu struct {
+	mu       sync.Mutex
+	item     *systray.MenuItem
+	entries  *menuSlots
+	entryIds []string
+	// entryDevices is the device slots of each entry, indexed by the slot index in entries.
+	entryDevices []*menuSlots
+}
+
+func addRecentMenuItem() {
+	if pushHistory == nil {
+		return
+	}
+	rm := &recentMenu{
+		item: systray.AddMenuItem("Recent", "Recently sent messages"),
+	}
+	clearMenuItem := rm.item.AddSubMenuItem("Clear history", "Clear history")
+	go func() {
+		for range clearMenuItem.ClickedCh {
+			if err := pushHistory.Clear(); err != nil {
+				logger.Error("Failed to clear the history: " + err.Error())
+			}
+		}
+	}()
+	rm.entries = newMenuSlots(rm.item, rm.addEntryMenu)
+	appRecentMenu = rm
+	pushHistory.SetOnChange(rm.refresh)
+	rm.refresh()
+}
+
+// addEntryMenu adds the sub menu of the entry at index, which copies the message
+// back to the clipboard or resends it to a device.
+func (rm *recentMenu) addEntryMenu(item *systray.MenuItem, index int) {
+	copyMenuItem := item.AddSubMenuItem("Copy to clipboard", "Copy to clipboard")
+	go func() {
+		for range copyMenuItem.ClickedCh {
+			if entry, ok := rm.entry(index); ok {
+				// The image itself is not kept, so its url is copied instead of the placeholder body
+				if config.IsImagePushRequest(entry.PushRequest) {
+					writeClipboardText(entry.PushRequest.Url)
+				} else {
+					writeClipboardText(entry.PushRequest.Body)
+				}
+			}
+		}
+	}()
+	devices := newMenuSlots(item, func(deviceItem *systray.MenuItem, deviceIndex int) {
+		go func() {
+			for range deviceItem.ClickedCh {
+				entry, ok := rm.entry(index)
+				device := deviceAt(deviceIndex)
+				if ok && device != nil {
+					pushRequest := *entry.PushRequest
+					if check := guardSecrets([]*config.Device{device}, &pushRequest, true); len(check.Allowed) > 0 {
+						pushToDevice(device, &pushRequest)
+					}
+				}
+			}
+		}()
+	})
+	rm.entryDevices = append(rm.entryDevices, devices)
+}
+
+// entry returns the entry at index of the menu, ok is false if it has been removed
+// or its message is redacted, in which case a notification is shown.
+func (rm *recentMenu) entry(index int) (entry history.Entry, ok bool) {
+	rm.mu.Lock()
+	var entryId string
+	if index < len(rm.entryIds) {
+		entryId = rm.entryIds[index]
+	}
+	rm.mu.Unlock()
+	entry, ok = pushHistory.Get(entryId)
+	if !ok {
+		return entry, false
+	}
+	if entry.IsRedacted() {
+		_ = zenity.Notify("The message is redacted in the history", zenity.InfoIcon)
+		return entry, false
+	}
+	return entry, true
+}
+
+// refresh updates the menu according to the entries in the history and the devices in appConfig.
+func (rm *recentMenu) refresh() {
+	currentConfig := appConfig.Load()
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	entries := pushHistory.Entries()
+	setMenuItemVisible(rm.item, len(entries) > 0)
+	rm.entryIds = rm.entryIds[:0]
+	var titles []string
+	for i := len(entries) - 1; i >= 0 && len(titles) < maxRecentMenuEntries; i-- {
+		rm.entryIds = append(rm.entryIds, entries[i].Id)
+		titles = append(titles, historyEntryTitle(&entries[i]))
+	}
+	rm.entries.update(titles)
+	deviceTitles := make([]string, len(currentConfig.Devices))
+	for i, device := range currentConfig.Devices {
+		deviceTitles[i] = "Send to " + device.Name
+	}
+	for _, devices := range rm.entryDevices {
+		devices.update(deviceTitles)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/LGiki/bark-tray/pkg/hotkeys"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"go.uber.org/zap"
+	"sort"
+)
+
+// registerHotkeys registers the global hotkeys in the config, they run the same
+// actions as the menu items. Invalid and conflicting hotkeys are skipped and logged.
+func registerHotkeys() {
+	currentConfig := appConfig.Load()
+	hotkeysConfig := currentConfig.Hotkeys
+	if hotkeysConfig == nil {
+		return
+	}
+	// actionNames is the names of the actions registered for each binding, to detect conflicts
+	actionNames := make(map[string]string)
+	register := func(spec string, actionName string, action func()) {
+		if spec == "" {
+			return
+		}
+		binding, err := hotkeys.Parse(spec)
+		if err != nil {
+			logger.Warn("Invalid hotkey", zap.String("action", actionName), zap.Error(err))
+			return
+		}
+		if registeredActionName, ok := actionNames[binding.String()]; ok {
+			logger.Warn("Hotkey conflicts with another action, ignored", zap.Stringer("hotkey", binding), zap.String("action", actionName), zap.String("conflictingAction", registeredActionName))
+			return
+		}
+		if err := hotkeys.Register(binding, action); err != nil {
+			logger.Error("Failed to register hotkey", zap.Stringer("hotkey", binding), zap.String("action", actionName), zap.Error(err))
+			return
+		}
+		actionNames[binding.String()] = actionName
+		logger.Info("Registered hotkey", zap.Stringer("hotkey", binding), zap.String("action", actionName))
+	}
+
+	if defaultDevice := currentConfig.GetDefaultDevice(); defaultDevice != nil {
+		register(hotkeysConfig.DefaultDevice, "the default device", func() {
+			pushMessageFromClipboard(defaultDevice, nil)
+		})
+	} else if hotkeysConfig.DefaultDevice != "" {
+		logger.Warn("Hotkey for the default device is ignored because there is no default device")
+	}
+	if len(currentConfig.Devices) > 0 {
+		register(hotkeysConfig.AllDevices, "all devices", func() {
+			pushMessageFromClipboardToDevices(currentConfig.Devices, nil)
+		})
+	}
+	// Register the device hotkeys in a stable order so that conflicts are resolved consistently
+	deviceNames := make([]string, 0, len(hotkeysConfig.Devices))
+	for deviceName := range hotkeysConfig.Devices {
+		deviceNames = append(deviceNames, deviceName)
+	}
+	sort.Strings(deviceNames)
+	for _, deviceName := range deviceNames {
+		device := currentConfig.GetDevice(deviceName)
+		if device == nil {
+			logger.Warn("Hotkey is ignored because the device does not exist", zap.String("device", deviceName))
+			continue
+		}
+		register(hotkeysConfig.Devices[deviceName], "device "+deviceName, func() {
+			pushMessageFromClipboard(device, nil)
+		})
+	}
+}
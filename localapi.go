@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/config"
+	"github.com/LGiki/bark-tray/pkg/localapi"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/ncruces/zenity"
+	"go.uber.org/zap"
+)
+
+var localApiServer *localapi.Server
+
+func localApiDevices() []localapi.Device {
+	currentConfig := appConfig.Load()
+	devices := make([]localapi.Device, len(currentConfig.Devices))
+	for i, device := range currentConfig.Devices {
+		devices[i] = localapi.Device{Name: device.Name, IsDefault: device.IsDefault}
+	}
+	return devices
+}
+
+// localApiPush sends the push request received by the local HTTP API
+// the same way as the menu items, except that no notification is shown, and the messages
+// containing secrets are not sent to the devices that require confirmation.
+func localApiPush(deviceNames []string, pushRequest *bark.PushRequest) []localapi.Result {
+	currentConfig := appConfig.Load()
+	devices := make([]*config.Device, 0, len(deviceNames))
+	for _, deviceName := range deviceNames {
+		if device := currentConfig.GetDevice(deviceName); device != nil {
+			devices = append(devices, device)
+		}
+	}
+	check := guardSecrets(devices, pushRequest, false)
+	var pushResults config.PushResults
+	if len(check.Allowed) > 0 {
+		pushResults = pushToDevices(check.Allowed, pushRequest)
+	}
+	results := make([]localapi.Result, 0, len(devices))
+	for _, pushResult := range pushResults {
+		result := localapi.Result{Device: pushResult.Device.Name}
+		if pushResult.Err != nil {
+			result.Error = pushResult.Err.Error()
+		}
+		results = append(results, result)
+	}
+	for _, device := range check.Blocked {
+		results = append(results, localapi.Result{
+			Device: device.Name,
+			Error:  "not sent, the message contains " + secretKindsText(check.Kinds),
+		})
+	}
+	return results
+}
+
+// startLocalApi starts the local HTTP API server if it is enabled in the config.
+func startLocalApi() {
+	options := appConfig.Load().LocalApi
+	if options == nil || !options.Enabled {
+		return
+	}
+	var err error
+	localApiServer, err = localapi.New(options, localApiDevices, localApiPush, pushMetrics.WritePrometheus)
+	if err == nil {
+		err = localApiServer.Start()
+	}
+	if err != nil {
+		localApiServer = nil
+		logMessage := "Failed to start the local HTTP API: " + err.Error()
+		logger.Error(logMessage)
+		_ = zenity.Notify(logMessage, zenity.ErrorIcon)
+		return
+	}
+	logger.Info("Local HTTP API is listening", zap.String("address", options.GetListen()))
+}
+
+func stopLocalApi() {
+	if localApiServer == nil {
+		return
+	}
+	if err := localApiServer.Shutdown(); err != nil {
+		logger.Warn("Failed to stop the local HTTP API: " + err.Error())
+	}
+	localApiServer = nil
+}
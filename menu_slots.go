@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/getlantern/systray"
+	"sync"
+)
+
+// menuSlots is a list of sub menu items under parent that can change at runtime.
+// systray does not support removing menu items, so the items are reused
+// when the list changes and the unused items are hidden.
+type menuSlots struct {
+	mu     sync.Mutex
+	parent *systray.MenuItem
+	items  []*systray.MenuItem
+	// onCreate is called once for each newly created item with its index,
+	// it is used to add sub menu items and start the click handlers.
+	onCreate func(item *systray.MenuItem, index int)
+}
+
+func newMenuSlots(parent *systray.MenuItem, onCreate func(item *systray.MenuItem, index int)) *menuSlots {
+	return &menuSlots{
+		parent:   parent,
+		onCreate: onCreate,
+	}
+}
+
+// update sets the titles of the items, more items are created if needed
+// and the items beyond titles are hidden.
+func (m *menuSlots) update(titles []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, title := range titles {
+		if i < len(m.items) {
+			m.items[i].SetTitle(title)
+			m.items[i].SetTooltip(title)
+			m.items[i].Show()
+			continue
+		}
+		item := m.parent.AddSubMenuItem(title, title)
+		m.items = append(m.items, item)
+		if m.onCreate != nil {
+			m.onCreate(item, i)
+		}
+	}
+	for i := len(titles); i < len(m.items); i++ {
+		m.items[i].Hide()
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/config"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/LGiki/bark-tray/pkg/metrics"
+	"github.com/getlantern/systray"
+	"github.com/ncruces/zenity"
+	"strings"
+	"time"
+)
+
+// pushMetrics records the pushes sent since Bark Tray is started, including the retries from the outbox.
+var pushMetrics = metrics.New()
+
+// recordPushMetrics adds results to pushMetrics.
+func recordPushMetrics(results config.PushResults) {
+	for _, result := range results {
+		pushMetrics.Record(result.Device.Name, result.Duration, result.Response, result.Err)
+	}
+}
+
+// addStatisticsMenuItem adds the "Statistics" menu item, which shows the statistics of the pushes to each device.
+func addStatisticsMenuItem() {
+	statisticsMenuItem := systray.AddMenuItem("Statistics", "Statistics of the sent messages")
+	go func() {
+		for range statisticsMenuItem.ClickedCh {
+			showStatisticsDialog()
+		}
+	}()
+}
+
+func showStatisticsDialog() {
+	err := zenity.Info(statisticsText(pushMetrics.StartedAt(), pushMetrics.Devices()),
+		zenity.Title("Statistics"),
+		zenity.NoIcon,
+		zenity.OKLabel("OK"),
+		zenity.ExtraButton("Reset"),
+	)
+	if err == zenity.ErrExtraButton {
+		pushMetrics.Reset()
+		logger.Info("Statistics reset")
+	}
+}
+
+// statisticsText returns the text of the "Statistics" dialog, e.g.
+//
+//	iPhone: 12 sent, 11 delivered, 1 failed (timeout: 1)
+//	Latency: average 320 ms, median ≤ 500 ms, 95th percentile ≤ 1 s, max 1.2 s
+//	Last delivered: 2006-01-02 15:04:05
+func statisticsText(startedAt time.Time, devices []metrics.DeviceStats) string {
+	since := "Since " + startedAt.Format("2006-01-02 15:04:05")
+	if len(devices) == 0 {
+		return since + "\n\nNo message has been sent."
+	}
+	sections := []string{since}
+	for _, stats := range devices {
+		lines := []string{fmt.Sprintf("%s: %d sent, %d delivered, %d failed", stats.Device, stats.Sent, stats.Delivered, stats.Failed())}
+		if stats.Failed() > 0 {
+			var failures []string
+			for _, class := range metrics.ErrorClasses {
+				if count := stats.Failures[class]; count > 0 {
+					failures = append(failures, fmt.Sprintf("%s: %d", strings.ReplaceAll(string(class), "_", " "), count))
+				}
+			}
+			lines[0] += " (" + strings.Join(failures, ", ") + ")"
+		}
+		latency := stats.Latency
+		lines = append(lines, fmt.Sprintf("Latency: average %s, median ≤ %s, 95th percentile ≤ %s, max %s",
+			durationText(latency.Mean()), durationText(latency.Quantile(0.5)), durationText(latency.Quantile(0.95)), durationText(latency.Max)))
+		if !stats.LastDeliveredAt.IsZero() {
+			lines = append(lines, "Last delivered: "+stats.LastDeliveredAt.Local().Format("2006-01-02 15:04:05"))
+		}
+		if stats.LastFailure != "" {
+			lines = append(lines, "Last failure: "+stats.LastFailure)
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// durationText returns d in milliseconds if it is shorter than a second, otherwise in seconds, e.g. "320 ms", "1.2 s".
+func durationText(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%d ms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.1f s", d.Seconds())
+}
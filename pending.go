@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/config"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/LGiki/bark-tray/pkg/outbox"
+	"github.com/getlantern/systray"
+	"github.com/ncruces/zenity"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	outboxFileName = "outbox.json"
+	// maxPendingMenuEntries is the maximum number of entries shown in the "Pending" menu,
+	// the newest entries are shown first.
+	maxPendingMenuEntries = 20
+	// maxPendingMenuTitleLength is the maximum length of the message shown in the "Pending" menu.
+	maxPendingMenuTitleLength = 30
+)
+
+var pushOutbox *outbox.Outbox
+
+// pushOutboxEntry sends the push request of entry to the device with the same name in appConfig.
+func pushOutboxEntry(entry *outbox.Entry) error {
+	device := appConfig.Load().GetDevice(entry.DeviceName)
+	if device == nil {
+		return fmt.Errorf("device '%s' not found", entry.DeviceName)
+	}
+	logger.Info("Retrying to send", append(device.LogFields(), logger.BodyFields(entry.PushRequest.Body)...)...)
+	startTime := time.Now()
+	pushResponse, err := device.PushWithResponse(entry.PushRequest)
+	pushMetrics.Record(device.Name, time.Since(startTime), pushResponse, err)
+	return err
+}
+
+func onOutboxEntryRetried(entry *outbox.Entry, err error) {
+	if err != nil {
+		logger.Error("Failed to resend", zap.String("device", entry.DeviceName), zap.Int("attempts", entry.Attempts), zap.Error(err))
+		return
+	}
+	logger.Info("Successfully resent", zap.String("device", entry.DeviceName), zap.Int("attempts", entry.Attempts))
+	_ = zenity.Notify(fmt.Sprintf("Pending message has been sent to '%s'", entry.DeviceName), zenity.InfoIcon)
+}
+
+// savePendingPush saves the push request failed with err to the outbox,
+// it returns false if the outbox is not available or the push is not retryable.
+func savePendingPush(device *config.Device, pushRequest *bark.PushRequest, err error) bool {
+	if pushOutbox == nil || !bark.IsRetryable(err) {
+		return false
+	}
+	if saveErr := pushOutbox.Add(device.Name, pushRequest, err); saveErr != nil {
+		logger.Error("Failed to save to the outbox", append(device.LogFields(), zap.Error(saveErr))...)
+		return false
+	}
+	return true
+}
+
+type pendingMenu struct {
+	mu       sync.Mutex
+	item     *systray.MenuItem
+	entries  *menuSlots
+	entryIds []string
+}
+
+func addPendingMenuItem() {
+	if pushOutbox == nil {
+		return
+	}
+	pm := &pendingMenu{
+		item: systray.AddMenuItem("Pending (0)", "Messages failed to send"),
+	}
+	retryAllMenuItem := pm.item.AddSubMenuItem("Retry all", "Retry all")
+	discardAllMenuItem := pm.item.AddSubMenuItem("Discard all", "Discard all")
+	go func() {
+		for {
+			select {
+			case <-retryAllMenuItem.ClickedCh:
+				pushOutbox.RetryAll()
+			case <-discardAllMenuItem.ClickedCh:
+				if err := pushOutbox.DiscardAll(); err != nil {
+					logger.Error("Failed to discard pending messages: " + err.Error())
+				}
+			}
+		}
+	}()
+	pm.entries = newMenuSlots(pm.item, func(item *systray.MenuItem, index int) {
+		retryMenuItem := item.AddSubMenuItem("Retry", "Retry")
+		discardMenuItem := item.AddSubMenuItem("Discard", "Discard")
+		go func() {
+			for {
+				select {
+				case <-retryMenuItem.ClickedCh:
+					if err := pushOutbox.Retry(pm.entryId(index)); err != nil {
+						_ = zenity.Notify("Failed to resend: "+err.Error(), zenity.ErrorIcon)
+					}
+				case <-discardMenuItem.ClickedCh:
+					if err := pushOutbox.Discard(pm.entryId(index)); err != nil {
+						logger.Error("Failed to discard pending message: " + err.Error())
+					}
+				}
+			}
+		}()
+	})
+	pushOutbox.SetOnChange(pm.refresh)
+	pm.refresh()
+}
+
+func (pm *pendingMenu) entryId(index int) string {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if index >= len(pm.entryIds) {
+		return ""
+	}
+	return pm.entryIds[index]
+}
+
+// refresh updates the menu according to the entries in the outbox.
+func (pm *pendingMenu) refresh() {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	entries := pushOutbox.Entries()
+	pm.item.SetTitle(fmt.Sprintf("Pending (%d)", len(entries)))
+	if len(entries) == 0 {
+		pm.item.Hide()
+	} else {
+		pm.item.Show()
+	}
+	pm.entryIds = pm.entryIds[:0]
+	var titles []string
+	for i := len(entries) - 1; i >= 0 && len(titles) < maxPendingMenuEntries; i-- {
+		entry := entries[i]
+		pm.entryIds = append(pm.entryIds, entry.Id)
+		titles = append(titles, fmt.Sprintf("%s: %s", entry.DeviceName, truncateText(entry.PushRequest.Body, maxPendingMenuTitleLength)))
+	}
+	pm.entries.update(titles)
+}
+
+// truncateText truncates text to at most maxLength characters and
+// replaces line breaks with spaces, so that it can be shown in a menu item.
+func truncateText(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) > maxLength {
+		runes = append(runes[:maxLength], []rune("...")...)
+	}
+	for i, r := range runes {
+		if r == '\n' || r == '\r' {
+			runes[i] = ' '
+		}
+	}
+	return string(runes)
+}
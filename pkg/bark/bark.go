@@ -3,10 +3,13 @@ package bark
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"github.com/LGiki/bark-tray/pkg/dispatcher"
 	"github.com/LGiki/bark-tray/pkg/httpClient"
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // PushLevel is the interruption level of the push message.
@@ -23,8 +26,20 @@ const (
 	// PushLevelPassive means the system adds the notification to the notification list
 	// without lighting up the screen or playing a sound.
 	PushLevelPassive PushLevel = "passive"
+	// PushLevelCritical means the system presents the notification immediately and
+	// plays a sound even if the device is muted or Do Not Disturb is enabled.
+	PushLevelCritical PushLevel = "critical"
 )
 
+// IsValid reports whether l is one of the push levels supported by Bark.
+func (l PushLevel) IsValid() bool {
+	switch l {
+	case PushLevelActive, PushLevelTimeSensitive, PushLevelPassive, PushLevelCritical:
+		return true
+	}
+	return false
+}
+
 // PushSound is the sound of the Bark push notification.
 // See <https://github.com/Finb/Bark/tree/master/Sounds>.
 type PushSound string
@@ -64,17 +79,41 @@ const (
 	PushSoundUpdate             PushSound = "update.caf"
 )
 
+// PushSounds is all the sounds built into the Bark App.
+var PushSounds = []PushSound{
+	PushSoundAlarm, PushSoundAnticipate, PushSoundBell, PushSoundBirdSong, PushSoundBloom,
+	PushSoundCalypso, PushSoundChime, PushSoundChoo, PushSoundDescent, PushSoundElectronic,
+	PushSoundFanfare, PushSoundGlass, PushSoundGotoSleep, PushSoundHealthNotification, PushSoundHorn,
+	PushSoundLadder, PushSoundMailSent, PushSoundMinuet, PushSoundMultiwayInvitation, PushSoundNewMail,
+	PushSoundNewsflash, PushSoundNoir, PushSoundPaymentSuccess, PushSoundShake, PushSoundSherwoodForest,
+	PushSoundSilence, PushSoundSpell, PushSoundSuspense, PushSoundTelegraph, PushSoundTiptoes,
+	PushSoundTypewriters, PushSoundUpdate,
+}
+
+// IsValid reports whether s is one of PushSounds, the ".caf" extension can be omitted.
+func (s PushSound) IsValid() bool {
+	for _, pushSound := range PushSounds {
+		if s == pushSound || s+".caf" == pushSound {
+			return true
+		}
+	}
+	return false
+}
+
 // PushRequest is the request struct for Bark API.
 // See <https://github.com/Finb/bark-server/blob/master/docs/API_V2.md#push>.
 type PushRequest struct {
 	// Title is the notification title which font size would be larger than the body.
 	Title string `json:"title,omitempty"`
-	// Body is the notification content. Required.
-	Body string `json:"body"`
+	// Body is the notification content. Required unless Ciphertext is set.
+	Body string `json:"body,omitempty"`
 	// Category is a reserved field, no use yet.
 	Category string `json:"category,omitempty"`
 	// DeviceKey is the key for each device. Required.
-	DeviceKey string `json:"device_key"`
+	DeviceKey string `json:"device_key,omitempty"`
+	// DeviceKeys is the keys of devices for batch push, DeviceKey is ignored if it is set. Optional.
+	// Only supported by the newer version of bark-server.
+	DeviceKeys []string `json:"device_keys,omitempty"`
 	// Level is the interruption level of the push message. Optional.
 	Level PushLevel `json:"level,omitempty"`
 	// Badge is the number displayed next to the app icon. Optional.
@@ -95,6 +134,22 @@ type PushRequest struct {
 	IsArchive string `json:"isArchive,omitempty"`
 	// Url is the url that will jump when click the notification. Optional.
 	Url string `json:"url,omitempty"`
+	// Call must be 1, the notification sound will be repeated for 30 seconds. Optional.
+	Call string `json:"call,omitempty"`
+	// Volume is the volume of the sound for PushLevelCritical, ranges from 0 to 10. Optional.
+	Volume int `json:"volume,omitempty"`
+	// Ciphertext is the encrypted push payload, all other fields except DeviceKey
+	// are ignored by the Bark App when it is set. Optional.
+	// See <https://github.com/Finb/Bark/blob/master/README.en.md#push-encryption>.
+	Ciphertext string `json:"ciphertext,omitempty"`
+	// Iv is the initialization vector used to encrypt Ciphertext. Optional.
+	Iv string `json:"iv,omitempty"`
+}
+
+// Text returns the text fields of the push request that are shown to or copied by the user,
+// i.e. Title, Body, Url and Copy, one per line, e.g. to inspect them for secrets.
+func (p *PushRequest) Text() string {
+	return p.Title + "\n" + p.Body + "\n" + p.Url + "\n" + p.Copy
 }
 
 // PushResponse is the response struct for Bark API.
@@ -104,6 +159,37 @@ type PushResponse struct {
 	Timestamp int64  `json:"timestamp"`
 }
 
+// PushError is returned when the Bark server does not accept the push request.
+type PushError struct {
+	// Code is the code of PushResponse, or the HTTP status code
+	// if the server does not respond with a PushResponse.
+	Code    int
+	Message string
+}
+
+func (e *PushError) Error() string {
+	return e.Message
+}
+
+// IsRetryable reports whether a push failed with err may succeed if it is sent again later.
+// Network errors and server errors are retryable, while errors caused by the
+// request itself, such as an invalid device key, are not.
+func IsRetryable(err error) bool {
+	var pushError *PushError
+	if errors.As(err, &pushError) {
+		return pushError.Code >= 500 || pushError.Code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// PushResult is the result of pushing to a single device key.
+type PushResult struct {
+	DeviceKey string
+	Response  *PushResponse
+	Err       error
+	Duration  time.Duration
+}
+
 func GetBarkPushUrl(barkBaseUrl string) (string, error) {
 	barkPushUrl, err := url.JoinPath(barkBaseUrl, "/push")
 	if err != nil {
@@ -112,11 +198,19 @@ func GetBarkPushUrl(barkBaseUrl string) (string, error) {
 	return barkPushUrl, nil
 }
 
-func Push(barkBaseUrl string, pushRequest *PushRequest) (*PushResponse, error) {
+// Push sends pushRequest to the Bark server at barkBaseUrl.
+// If encryption is not nil, the pushRequest will be encrypted and sent as ciphertext.
+func Push(barkBaseUrl string, pushRequest *PushRequest, encryption *Encryption) (*PushResponse, error) {
 	barkPushUrl, err := GetBarkPushUrl(barkBaseUrl)
 	if err != nil {
 		return nil, err
 	}
+	if encryption != nil {
+		pushRequest, err = encryption.EncryptPushRequest(pushRequest)
+		if err != nil {
+			return nil, err
+		}
+	}
 	pushRequestBytes, err := json.Marshal(pushRequest)
 	if err != nil {
 		return nil, err
@@ -139,12 +233,15 @@ func Push(barkBaseUrl string, pushRequest *PushRequest) (*PushResponse, error) {
 	var pushResp PushResponse
 	err = json.Unmarshal(body, &pushResp)
 	if err != nil {
+		if response.StatusCode != http.StatusOK {
+			return nil, &PushError{Code: response.StatusCode, Message: response.Status}
+		}
 		return nil, err
 	}
 	return &pushResp, nil
 }
 
-func PushTextMessage(barkBaseUrl string, deviceKey string, message string, messageUrl string) (*PushResponse, error) {
+func PushTextMessage(barkBaseUrl string, deviceKey string, message string, messageUrl string, encryption *Encryption) (*PushResponse, error) {
 	pushRequest := &PushRequest{
 		Body:      message,
 		DeviceKey: deviceKey,
@@ -152,5 +249,74 @@ func PushTextMessage(barkBaseUrl string, deviceKey string, message string, messa
 	if messageUrl != "" {
 		pushRequest.Url = messageUrl
 	}
-	return Push(barkBaseUrl, pushRequest)
+	return Push(barkBaseUrl, pushRequest, encryption)
+}
+
+// BatchPush sends pushRequest to all deviceKeys on the Bark server at barkBaseUrl.
+// It first tries a single batch request using PushRequest.DeviceKeys,
+// if the server does not support it, it falls back to concurrent requests for each device key.
+// Any other result of the batch request, e.g. a timeout or a server error, is the result of
+// every device key, since the message may have been delivered to some of them.
+// The returned results are in the same order as deviceKeys.
+func BatchPush(barkBaseUrl string, pushRequest *PushRequest, deviceKeys []string) []*PushResult {
+	d := dispatcher.New(len(deviceKeys))
+	results := DispatchBatchPush(d, barkBaseUrl, pushRequest, deviceKeys)
+	d.Wait()
+	return results
+}
+
+// DispatchBatchPush is BatchPush with the requests run as jobs of d, so that they are limited
+// by d together with its other jobs. The returned results are filled once d.Wait returns.
+func DispatchBatchPush(d *dispatcher.Dispatcher, barkBaseUrl string, pushRequest *PushRequest, deviceKeys []string) []*PushResult {
+	results := make([]*PushResult, len(deviceKeys))
+	pushEach := func() {
+		for i, deviceKey := range deviceKeys {
+			i, deviceKey := i, deviceKey
+			d.Go(func() {
+				singlePushRequest := *pushRequest
+				singlePushRequest.DeviceKey = deviceKey
+				singlePushRequest.DeviceKeys = nil
+				startTime := time.Now()
+				pushResponse, err := Push(barkBaseUrl, &singlePushRequest, nil)
+				results[i] = &PushResult{DeviceKey: deviceKey, Response: pushResponse, Err: err, Duration: time.Since(startTime)}
+			})
+		}
+	}
+	if len(deviceKeys) < 2 {
+		pushEach()
+		return results
+	}
+
+	d.Go(func() {
+		batchPushRequest := *pushRequest
+		batchPushRequest.DeviceKey = ""
+		batchPushRequest.DeviceKeys = deviceKeys
+		startTime := time.Now()
+		pushResponse, err := Push(barkBaseUrl, &batchPushRequest, nil)
+		duration := time.Since(startTime)
+		if isBatchUnsupported(pushResponse, err) {
+			pushEach()
+			return
+		}
+		for i, deviceKey := range deviceKeys {
+			results[i] = &PushResult{DeviceKey: deviceKey, Response: pushResponse, Err: err, Duration: duration}
+		}
+	})
+	return results
+}
+
+// isBatchUnsupported reports whether the batch request failed with pushResponse or err because
+// the server does not support PushRequest.DeviceKeys, in which case nothing has been sent:
+// an older bark-server rejects the request without a device key with 400 Bad Request,
+// and a server without the batch push responds with 404 Not Found.
+func isBatchUnsupported(pushResponse *PushResponse, err error) bool {
+	code := 0
+	var pushError *PushError
+	switch {
+	case err == nil:
+		code = pushResponse.Code
+	case errors.As(err, &pushError):
+		code = pushError.Code
+	}
+	return code == http.StatusBadRequest || code == http.StatusNotFound
 }
@@ -0,0 +1,103 @@
+package bark
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/LGiki/bark-tray/pkg/httpClient"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestBatchPush(t *testing.T) {
+	var requests []PushRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pushRequest PushRequest
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&pushRequest))
+		requests = append(requests, pushRequest)
+		_, _ = w.Write([]byte(`{"code":200,"message":"success","timestamp":1}`))
+	}))
+	defer server.Close()
+	httpClient.Setup("Bark Tray Test", 5)
+
+	results := BatchPush(server.URL, &PushRequest{Body: "hello"}, []string{"a", "b", "c"})
+	assert.Len(t, requests, 1)
+	assert.Equal(t, []string{"a", "b", "c"}, requests[0].DeviceKeys)
+	assert.Equal(t, "", requests[0].DeviceKey)
+	for i, deviceKey := range []string{"a", "b", "c"} {
+		assert.Equal(t, deviceKey, results[i].DeviceKey)
+		assert.Nil(t, results[i].Err)
+		assert.Equal(t, 200, results[i].Response.Code)
+	}
+}
+
+func TestBatchPushFallback(t *testing.T) {
+	var mu sync.Mutex
+	var deviceKeys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pushRequest PushRequest
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&pushRequest))
+		if pushRequest.DeviceKey == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":400,"message":"device key is empty","timestamp":1}`))
+			return
+		}
+		mu.Lock()
+		deviceKeys = append(deviceKeys, pushRequest.DeviceKey)
+		mu.Unlock()
+		if pushRequest.DeviceKey == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":400,"message":"failed to get device token","timestamp":1}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":200,"message":"success","timestamp":1}`))
+	}))
+	defer server.Close()
+	httpClient.Setup("Bark Tray Test", 5)
+
+	results := BatchPush(server.URL, &PushRequest{Body: "hello"}, []string{"a", "bad", "c"})
+	assert.ElementsMatch(t, []string{"a", "bad", "c"}, deviceKeys)
+	assert.Equal(t, 200, results[0].Response.Code)
+	assert.Equal(t, 400, results[1].Response.Code)
+	assert.Equal(t, 200, results[2].Response.Code)
+}
+
+func TestBatchPushServerError(t *testing.T) {
+	var mu sync.Mutex
+	requestCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requestCount++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"code":503,"message":"service unavailable","timestamp":1}`))
+	}))
+	defer server.Close()
+	httpClient.Setup("Bark Tray Test", 5)
+
+	results := BatchPush(server.URL, &PushRequest{Body: "hello"}, []string{"a", "b", "c"})
+	// The message is not sent to each device key again, which could deliver it twice
+	assert.Equal(t, 1, requestCount)
+	for i, deviceKey := range []string{"a", "b", "c"} {
+		assert.Equal(t, deviceKey, results[i].DeviceKey)
+		assert.Nil(t, results[i].Err)
+		assert.Equal(t, 503, results[i].Response.Code)
+	}
+}
+
+func TestIsBatchUnsupported(t *testing.T) {
+	assert.True(t, isBatchUnsupported(&PushResponse{Code: 400, Message: "device key is empty"}, nil))
+	assert.True(t, isBatchUnsupported(&PushResponse{Code: 404, Message: "not found"}, nil))
+	assert.False(t, isBatchUnsupported(&PushResponse{Code: 200, Message: "success"}, nil))
+	assert.False(t, isBatchUnsupported(&PushResponse{Code: 500, Message: "internal server error"}, nil))
+	assert.False(t, isBatchUnsupported(nil, context.DeadlineExceeded))
+	assert.True(t, isBatchUnsupported(nil, &PushError{Code: 404, Message: "404 Not Found"}))
+	assert.False(t, isBatchUnsupported(nil, &PushError{Code: 502, Message: "502 Bad Gateway"}))
+}
+
+func TestPushRequestText(t *testing.T) {
+	pushRequest := &PushRequest{Title: "title", Body: "body", Url: "https://example.com", Copy: "copy", Group: "group"}
+	assert.Equal(t, "title\nbody\nhttps://example.com\ncopy", pushRequest.Text())
+}
@@ -0,0 +1,183 @@
+package bark
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// EncryptionAlgorithm is the algorithm used to encrypt the push message.
+// See <https://github.com/Finb/Bark/blob/master/README.en.md#push-encryption>.
+type EncryptionAlgorithm string
+
+const (
+	EncryptionAlgorithmAES128 EncryptionAlgorithm = "AES128"
+	EncryptionAlgorithmAES192 EncryptionAlgorithm = "AES192"
+	EncryptionAlgorithmAES256 EncryptionAlgorithm = "AES256"
+)
+
+// EncryptionMode is the block cipher mode used to encrypt the push message.
+type EncryptionMode string
+
+const (
+	EncryptionModeCBC EncryptionMode = "CBC"
+	EncryptionModeECB EncryptionMode = "ECB"
+	EncryptionModeGCM EncryptionMode = "GCM"
+)
+
+// ivCharset is the charset used to generate a random IV,
+// the Bark App reads the IV as an UTF-8 string, so it must be printable.
+const ivCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Encryption is the encryption setting of a device,
+// it must be the same as the setting in the Bark App.
+type Encryption struct {
+	// Algorithm is one of AES128, AES192 and AES256.
+	Algorithm EncryptionAlgorithm `json:"algorithm"`
+	// Mode is one of CBC, ECB and GCM.
+	Mode EncryptionMode `json:"mode"`
+	// Key is the encryption key, its length must match the Algorithm,
+	// that is 16, 24 and 32 characters for AES128, AES192 and AES256.
+	Key string `json:"key"`
+	// Iv is the initialization vector, it must be 16 characters for CBC
+	// and 12 characters for GCM, and is not used by ECB.
+	// A random IV will be generated for each push if it is empty.
+	Iv string `json:"iv"`
+}
+
+// keyLength returns the key length in bytes required by the algorithm.
+func (e *Encryption) keyLength() int {
+	switch e.Algorithm {
+	case EncryptionAlgorithmAES128:
+		return 16
+	case EncryptionAlgorithmAES192:
+		return 24
+	case EncryptionAlgorithmAES256:
+		return 32
+	}
+	return 0
+}
+
+// ivLength returns the IV length in bytes required by the mode.
+func (e *Encryption) ivLength() int {
+	switch e.Mode {
+	case EncryptionModeCBC:
+		return aes.BlockSize
+	case EncryptionModeGCM:
+		return 12
+	}
+	return 0
+}
+
+// Validate checks if the encryption setting is usable.
+func (e *Encryption) Validate() error {
+	keyLength := e.keyLength()
+	if keyLength == 0 {
+		return fmt.Errorf("unsupported encryption algorithm '%s'", e.Algorithm)
+	}
+	switch e.Mode {
+	case EncryptionModeCBC, EncryptionModeECB, EncryptionModeGCM:
+	default:
+		return fmt.Errorf("unsupported encryption mode '%s'", e.Mode)
+	}
+	if len(e.Key) != keyLength {
+		return fmt.Errorf("encryption key must be %d characters for %s, got %d", keyLength, e.Algorithm, len(e.Key))
+	}
+	if e.Iv != "" {
+		if e.Mode == EncryptionModeECB {
+			return fmt.Errorf("encryption iv is not used by %s", e.Mode)
+		}
+		if len(e.Iv) != e.ivLength() {
+			return fmt.Errorf("encryption iv must be %d characters for %s, got %d", e.ivLength(), e.Mode, len(e.Iv))
+		}
+	}
+	return nil
+}
+
+// Encrypt encrypts the plaintext with iv and returns the base64 encoded ciphertext.
+// For GCM, the authentication tag is appended to the ciphertext.
+func (e *Encryption) Encrypt(plaintext []byte, iv string) (string, error) {
+	if err := e.Validate(); err != nil {
+		return "", err
+	}
+	if len(iv) != e.ivLength() {
+		return "", fmt.Errorf("encryption iv must be %d characters for %s, got %d", e.ivLength(), e.Mode, len(iv))
+	}
+	block, err := aes.NewCipher([]byte(e.Key))
+	if err != nil {
+		return "", err
+	}
+	var ciphertext []byte
+	switch e.Mode {
+	case EncryptionModeCBC:
+		padded := pkcs7Pad(plaintext, block.BlockSize())
+		ciphertext = make([]byte, len(padded))
+		cipher.NewCBCEncrypter(block, []byte(iv)).CryptBlocks(ciphertext, padded)
+	case EncryptionModeECB:
+		padded := pkcs7Pad(plaintext, block.BlockSize())
+		ciphertext = make([]byte, len(padded))
+		for i := 0; i < len(padded); i += block.BlockSize() {
+			block.Encrypt(ciphertext[i:i+block.BlockSize()], padded[i:i+block.BlockSize()])
+		}
+	case EncryptionModeGCM:
+		gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+		if err != nil {
+			return "", err
+		}
+		ciphertext = gcm.Seal(nil, []byte(iv), plaintext, nil)
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// EncryptPushRequest serializes pushRequest and encrypts it,
+// the returned PushRequest only carries the device key, the ciphertext and the iv.
+func (e *Encryption) EncryptPushRequest(pushRequest *PushRequest) (*PushRequest, error) {
+	iv := e.Iv
+	if iv == "" && e.Mode != EncryptionModeECB {
+		var err error
+		iv, err = randomIv(e.ivLength())
+		if err != nil {
+			return nil, err
+		}
+	}
+	payload := *pushRequest
+	payload.DeviceKey = ""
+	payloadBytes, err := json.Marshal(&payload)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := e.Encrypt(payloadBytes, iv)
+	if err != nil {
+		return nil, err
+	}
+	return &PushRequest{
+		DeviceKey:  pushRequest.DeviceKey,
+		Ciphertext: ciphertext,
+		Iv:         iv,
+	}, nil
+}
+
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	padding := blockSize - len(data)%blockSize
+	padded := make([]byte, len(data), len(data)+padding)
+	copy(padded, data)
+	return append(padded, bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
+
+func randomIv(length int) (string, error) {
+	iv := make([]byte, length)
+	max := big.NewInt(int64(len(ivCharset)))
+	for i := range iv {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		iv[i] = ivCharset[n.Int64()]
+	}
+	return string(iv), nil
+}
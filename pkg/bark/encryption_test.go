@@ -0,0 +1,110 @@
+package bark
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/httpClient"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// decrypt is a local decryptor which behaves like the Bark App.
+func decrypt(t *testing.T, e *Encryption, ciphertext string, iv string) []byte {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	assert.Nil(t, err)
+	block, err := aes.NewCipher([]byte(e.Key))
+	assert.Nil(t, err)
+	var plaintext []byte
+	switch e.Mode {
+	case EncryptionModeCBC:
+		plaintext = make([]byte, len(data))
+		cipher.NewCBCDecrypter(block, []byte(iv)).CryptBlocks(plaintext, data)
+		plaintext = plaintext[:len(plaintext)-int(plaintext[len(plaintext)-1])]
+	case EncryptionModeECB:
+		plaintext = make([]byte, len(data))
+		for i := 0; i < len(data); i += aes.BlockSize {
+			block.Decrypt(plaintext[i:i+aes.BlockSize], data[i:i+aes.BlockSize])
+		}
+		plaintext = plaintext[:len(plaintext)-int(plaintext[len(plaintext)-1])]
+	case EncryptionModeGCM:
+		gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+		assert.Nil(t, err)
+		plaintext, err = gcm.Open(nil, []byte(iv), data, nil)
+		assert.Nil(t, err)
+	}
+	return plaintext
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	keys := map[EncryptionAlgorithm]string{
+		EncryptionAlgorithmAES128: "1234567890abcdef",
+		EncryptionAlgorithmAES192: "1234567890abcdef12345678",
+		EncryptionAlgorithmAES256: "1234567890abcdef1234567890abcdef",
+	}
+	ivs := map[EncryptionMode]string{
+		EncryptionModeCBC: "abcdef1234567890",
+		EncryptionModeECB: "",
+		EncryptionModeGCM: "abcdef123456",
+	}
+	for algorithm, key := range keys {
+		for mode, iv := range ivs {
+			t.Run(fmt.Sprintf("%s-%s", algorithm, mode), func(t *testing.T) {
+				e := &Encryption{Algorithm: algorithm, Mode: mode, Key: key, Iv: iv}
+				assert.Nil(t, e.Validate())
+				pushRequest := &PushRequest{Body: "hello, bark", Url: "https://example.org", DeviceKey: "device"}
+				encrypted, err := e.EncryptPushRequest(pushRequest)
+				assert.Nil(t, err)
+				assert.Equal(t, "device", encrypted.DeviceKey)
+				assert.Equal(t, "", encrypted.Body)
+				assert.Equal(t, iv, encrypted.Iv)
+				var decrypted PushRequest
+				assert.Nil(t, json.Unmarshal(decrypt(t, e, encrypted.Ciphertext, encrypted.Iv), &decrypted))
+				assert.Equal(t, "hello, bark", decrypted.Body)
+				assert.Equal(t, "https://example.org", decrypted.Url)
+				assert.Equal(t, "", decrypted.DeviceKey)
+			})
+		}
+	}
+}
+
+func TestEncryptionRandomIv(t *testing.T) {
+	e := &Encryption{Algorithm: EncryptionAlgorithmAES128, Mode: EncryptionModeGCM, Key: "1234567890abcdef"}
+	encrypted, err := e.EncryptPushRequest(&PushRequest{Body: "hello"})
+	assert.Nil(t, err)
+	assert.Len(t, encrypted.Iv, 12)
+	assert.Equal(t, `{"body":"hello"}`, string(decrypt(t, e, encrypted.Ciphertext, encrypted.Iv)))
+}
+
+func TestEncryptionValidate(t *testing.T) {
+	assert.NotNil(t, (&Encryption{Algorithm: "AES512", Mode: EncryptionModeCBC, Key: "1234567890abcdef"}).Validate())
+	assert.NotNil(t, (&Encryption{Algorithm: EncryptionAlgorithmAES128, Mode: "CTR", Key: "1234567890abcdef"}).Validate())
+	assert.NotNil(t, (&Encryption{Algorithm: EncryptionAlgorithmAES256, Mode: EncryptionModeCBC, Key: "1234567890abcdef"}).Validate())
+	assert.NotNil(t, (&Encryption{Algorithm: EncryptionAlgorithmAES128, Mode: EncryptionModeCBC, Key: "1234567890abcdef", Iv: "short"}).Validate())
+	assert.NotNil(t, (&Encryption{Algorithm: EncryptionAlgorithmAES128, Mode: EncryptionModeECB, Key: "1234567890abcdef", Iv: "abcdef1234567890"}).Validate())
+	assert.Nil(t, (&Encryption{Algorithm: EncryptionAlgorithmAES128, Mode: EncryptionModeCBC, Key: "1234567890abcdef"}).Validate())
+}
+
+func TestPushEncrypted(t *testing.T) {
+	e := &Encryption{Algorithm: EncryptionAlgorithmAES256, Mode: EncryptionModeCBC, Key: "1234567890abcdef1234567890abcdef", Iv: "abcdef1234567890"}
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/push", r.URL.Path)
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+		_, _ = w.Write([]byte(`{"code":200,"message":"success","timestamp":1}`))
+	}))
+	defer server.Close()
+	httpClient.Setup("Bark Tray Test", 5)
+
+	pushResponse, err := PushTextMessage(server.URL, "device", "secret text", "", e)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, pushResponse.Code)
+	assert.Equal(t, "device", received["device_key"])
+	assert.Equal(t, "abcdef1234567890", received["iv"])
+	assert.NotContains(t, received, "body")
+	assert.Equal(t, `{"body":"secret text"}`, string(decrypt(t, e, received["ciphertext"], received["iv"])))
+}
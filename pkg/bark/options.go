@@ -0,0 +1,69 @@
+package bark
+
+import (
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/util"
+)
+
+// PushOptions are the optional fields of PushRequest which can be preset
+// for every push, e.g. the default sound of a device.
+type PushOptions struct {
+	Level     PushLevel `json:"level,omitempty"`
+	Sound     PushSound `json:"sound,omitempty"`
+	Group     string    `json:"group,omitempty"`
+	Icon      string    `json:"icon,omitempty"`
+	Badge     int       `json:"badge,omitempty"`
+	IsArchive bool      `json:"isArchive,omitempty"`
+	Call      bool      `json:"call,omitempty"`
+	// Volume only takes effect when Level is PushLevelCritical.
+	Volume int `json:"volume,omitempty"`
+}
+
+// Validate checks if all options are supported by Bark.
+func (o *PushOptions) Validate() error {
+	if o.Level != "" && !o.Level.IsValid() {
+		return fmt.Errorf("unsupported push level '%s'", o.Level)
+	}
+	if o.Sound != "" && !o.Sound.IsValid() {
+		return fmt.Errorf("unsupported push sound '%s'", o.Sound)
+	}
+	if o.Icon != "" && !util.IsValidHttpUrl(o.Icon) {
+		return fmt.Errorf("invalid push icon url '%s'", o.Icon)
+	}
+	if o.Badge < 0 {
+		return fmt.Errorf("push badge must not be negative, got %d", o.Badge)
+	}
+	if o.Volume < 0 || o.Volume > 10 {
+		return fmt.Errorf("push volume must be between 0 and 10, got %d", o.Volume)
+	}
+	return nil
+}
+
+// Apply sets the options to the fields of pushRequest which are not set yet,
+// so the options never override what has been set for a specific push.
+func (o *PushOptions) Apply(pushRequest *PushRequest) {
+	if pushRequest.Level == "" {
+		pushRequest.Level = o.Level
+	}
+	if pushRequest.Sound == "" {
+		pushRequest.Sound = string(o.Sound)
+	}
+	if pushRequest.Group == "" {
+		pushRequest.Group = o.Group
+	}
+	if pushRequest.Icon == "" {
+		pushRequest.Icon = o.Icon
+	}
+	if pushRequest.Badge == 0 {
+		pushRequest.Badge = o.Badge
+	}
+	if pushRequest.IsArchive == "" && o.IsArchive {
+		pushRequest.IsArchive = "1"
+	}
+	if pushRequest.Call == "" && o.Call {
+		pushRequest.Call = "1"
+	}
+	if pushRequest.Volume == 0 {
+		pushRequest.Volume = o.Volume
+	}
+}
@@ -0,0 +1,41 @@
+package bark
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPushOptionsValidate(t *testing.T) {
+	assert.Nil(t, (&PushOptions{}).Validate())
+	assert.Nil(t, (&PushOptions{Level: PushLevelCritical, Sound: PushSoundMinuet, Volume: 5}).Validate())
+	assert.Nil(t, (&PushOptions{Sound: "minuet"}).Validate())
+	assert.NotNil(t, (&PushOptions{Level: "loud"}).Validate())
+	assert.NotNil(t, (&PushOptions{Sound: "beep.caf"}).Validate())
+	assert.NotNil(t, (&PushOptions{Icon: "icon.png"}).Validate())
+	assert.NotNil(t, (&PushOptions{Badge: -1}).Validate())
+	assert.NotNil(t, (&PushOptions{Volume: 11}).Validate())
+}
+
+func TestPushOptionsApply(t *testing.T) {
+	options := &PushOptions{
+		Level:     PushLevelTimeSensitive,
+		Sound:     PushSoundBell,
+		Group:     "clipboard",
+		Icon:      "https://example.org/icon.png",
+		Badge:     1,
+		IsArchive: true,
+		Call:      true,
+	}
+	pushRequest := &PushRequest{Body: "hello", Group: "links"}
+	options.Apply(pushRequest)
+	assert.Equal(t, &PushRequest{
+		Body:      "hello",
+		Level:     PushLevelTimeSensitive,
+		Sound:     "bell.caf",
+		Group:     "links",
+		Icon:      "https://example.org/icon.png",
+		Badge:     1,
+		IsArchive: "1",
+		Call:      "1",
+	}, pushRequest)
+}
@@ -0,0 +1,206 @@
+package clipwatcher
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultDebounce = 500 * time.Millisecond
+)
+
+// Options is the setting of watching the clipboard.
+type Options struct {
+	// Enabled is whether to start watching the clipboard on startup.
+	Enabled bool `json:"enabled"`
+	// DebounceMs is the time in milliseconds to wait for the clipboard to settle,
+	// only the last text copied within it is pushed. Defaults to 500.
+	DebounceMs int `json:"debounceMs"`
+	// MinLength is the minimum number of characters of the text to push.
+	MinLength int `json:"minLength"`
+	// MaxLength is the maximum number of characters of the text to push, 0 means no limit.
+	MaxLength int `json:"maxLength"`
+	// Include is a list of regular expressions, if it is not empty,
+	// only the text matching at least one of them is pushed.
+	Include []string `json:"include"`
+	// Exclude is a list of regular expressions, the text matching any of them is not pushed.
+	Exclude []string `json:"exclude"`
+}
+
+// Filter decides whether a copied text should be pushed.
+type Filter struct {
+	minLength int
+	maxLength int
+	include   []*regexp.Regexp
+	exclude   []*regexp.Regexp
+}
+
+// NewFilter compiles the filter rules in options.
+func NewFilter(options *Options) (*Filter, error) {
+	if options.MinLength < 0 || options.MaxLength < 0 {
+		return nil, fmt.Errorf("minLength and maxLength must not be negative")
+	}
+	if options.MaxLength > 0 && options.MaxLength < options.MinLength {
+		return nil, fmt.Errorf("maxLength must not be less than minLength")
+	}
+	filter := &Filter{
+		minLength: options.MinLength,
+		maxLength: options.MaxLength,
+	}
+	for _, pattern := range options.Include {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern '%s': %s", pattern, err.Error())
+		}
+		filter.include = append(filter.include, re)
+	}
+	for _, pattern := range options.Exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern '%s': %s", pattern, err.Error())
+		}
+		filter.exclude = append(filter.exclude, re)
+	}
+	return filter, nil
+}
+
+// Match reports whether text should be pushed, empty text is never pushed.
+func (f *Filter) Match(text string) bool {
+	length := utf8.RuneCountInString(text)
+	if length == 0 || length < f.minLength || (f.maxLength > 0 && length > f.maxLength) {
+		return false
+	}
+	for _, re := range f.exclude {
+		if re.MatchString(text) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchFunc returns a channel which receives the clipboard content every time it changes,
+// the channel is closed when ctx is done, e.g. clipboard.Watch.
+type WatchFunc func(ctx context.Context) <-chan []byte
+
+// Watcher pushes every new text copied to the clipboard with debouncing,
+// duplicate suppression and filtering.
+type Watcher struct {
+	mu       sync.Mutex
+	watch    WatchFunc
+	push     func(text string)
+	filter   *Filter
+	debounce time.Duration
+	cancel   context.CancelFunc
+	lastText string
+}
+
+// New returns a Watcher which watches the clipboard with watch and calls push
+// for every text that should be pushed.
+func New(options *Options, watch WatchFunc, push func(text string)) (*Watcher, error) {
+	filter, err := NewFilter(options)
+	if err != nil {
+		return nil, err
+	}
+	debounce := defaultDebounce
+	if options.DebounceMs > 0 {
+		debounce = time.Duration(options.DebounceMs) * time.Millisecond
+	}
+	return &Watcher{
+		watch:    watch,
+		push:     push,
+		filter:   filter,
+		debounce: debounce,
+	}, nil
+}
+
+// IsRunning reports whether the watcher is watching the clipboard.
+func (w *Watcher) IsRunning() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.cancel != nil
+}
+
+// Start starts watching the clipboard, it does nothing if the watcher is already running.
+func (w *Watcher) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	go w.run(ctx, w.watch(ctx))
+}
+
+// Stop stops watching the clipboard.
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancel != nil {
+		w.cancel()
+		w.cancel = nil
+	}
+}
+
+// Ignore makes the watcher treat text as already pushed, it should be called before
+// the application itself writes text to the clipboard, so that it is not pushed again.
+func (w *Watcher) Ignore(text string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastText = strings.TrimSpace(text)
+}
+
+func (w *Watcher) run(ctx context.Context, changes <-chan []byte) {
+	timer := time.NewTimer(w.debounce)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	var pendingText string
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case content, ok := <-changes:
+			if !ok {
+				timer.Stop()
+				return
+			}
+			pendingText = strings.TrimSpace(string(content))
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(w.debounce)
+		case <-timer.C:
+			w.handle(pendingText)
+		}
+	}
+}
+
+// handle pushes text if it is not a duplicate of the last pushed text and matches the filter.
+func (w *Watcher) handle(text string) {
+	w.mu.Lock()
+	if text == w.lastText || !w.filter.Match(text) {
+		w.mu.Unlock()
+		return
+	}
+	w.lastText = text
+	w.mu.Unlock()
+	w.push(text)
+}
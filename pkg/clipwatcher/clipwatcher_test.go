@@ -0,0 +1,75 @@
+package clipwatcher
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	filter, err := NewFilter(&Options{
+		MinLength: 4,
+		MaxLength: 10,
+		Include:   []string{`^\d+$`, `^https?://`},
+		Exclude:   []string{`^0000$`},
+	})
+	assert.Nil(t, err)
+	assert.True(t, filter.Match("123456"))
+	assert.True(t, filter.Match("http://a.b"))
+	assert.False(t, filter.Match(""))
+	assert.False(t, filter.Match("123"))
+	assert.False(t, filter.Match("12345678901"))
+	assert.False(t, filter.Match("hello"))
+	assert.False(t, filter.Match("0000"))
+
+	filter, err = NewFilter(&Options{})
+	assert.Nil(t, err)
+	assert.True(t, filter.Match("anything"))
+	assert.False(t, filter.Match(""))
+
+	_, err = NewFilter(&Options{Include: []string{"("}})
+	assert.NotNil(t, err)
+	_, err = NewFilter(&Options{MinLength: 10, MaxLength: 5})
+	assert.NotNil(t, err)
+}
+
+func TestWatcher(t *testing.T) {
+	changes := make(chan []byte)
+	var mu sync.Mutex
+	var pushed []string
+	w, err := New(&Options{DebounceMs: 20, Exclude: []string{"secret"}}, func(ctx context.Context) <-chan []byte {
+		return changes
+	}, func(text string) {
+		mu.Lock()
+		defer mu.Unlock()
+		pushed = append(pushed, text)
+	})
+	assert.Nil(t, err)
+	w.Start()
+	assert.True(t, w.IsRunning())
+
+	// Only the last text copied within the debounce time is pushed
+	changes <- []byte("first")
+	changes <- []byte("second")
+	time.Sleep(60 * time.Millisecond)
+	// Duplicates are suppressed
+	changes <- []byte(" second ")
+	time.Sleep(60 * time.Millisecond)
+	// Excluded texts are not pushed
+	changes <- []byte("my secret")
+	time.Sleep(60 * time.Millisecond)
+	changes <- []byte("third")
+	time.Sleep(60 * time.Millisecond)
+	// Texts written by the application are not pushed
+	w.Ignore("copied back")
+	changes <- []byte("copied back")
+	time.Sleep(60 * time.Millisecond)
+
+	w.Stop()
+	assert.False(t, w.IsRunning())
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"second", "third"}, pushed)
+}
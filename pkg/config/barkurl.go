@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// barkKeyPattern matches the device keys generated by bark-server.
+var barkKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]{22}$`)
+
+// ParseBarkUrl extracts the Bark server url and the device key from a Bark url,
+// such as the one copied from the Bark App, e.g. "https://api.day.app/KEY/title/body".
+// Self-hosted servers under a path prefix, e.g. "https://example.org/bark/KEY/body",
+// and the urls with the device_key query parameter are also supported.
+func ParseBarkUrl(barkUrl string) (barkBaseUrl string, key string, err error) {
+	parsedUrl, err := url.Parse(strings.TrimSpace(barkUrl))
+	if err != nil {
+		return "", "", err
+	}
+	if (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		return "", "", fmt.Errorf("'%s' is not a valid Bark url", barkUrl)
+	}
+	var segments []string
+	for _, segment := range strings.Split(parsedUrl.Path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	keyIndex := -1
+	if deviceKey := parsedUrl.Query().Get("device_key"); deviceKey != "" {
+		// e.g. https://api.day.app/push?device_key=KEY
+		key = deviceKey
+		keyIndex = len(segments)
+		if keyIndex > 0 && segments[keyIndex-1] == "push" {
+			keyIndex--
+		}
+	} else {
+		for i, segment := range segments {
+			if barkKeyPattern.MatchString(segment) {
+				keyIndex = i
+				break
+			}
+		}
+		if keyIndex < 0 && len(segments) == 1 && segments[0] != "push" {
+			// A custom key which is not generated by bark-server
+			keyIndex = 0
+		}
+		if keyIndex < 0 {
+			return "", "", fmt.Errorf("no device key found in '%s'", barkUrl)
+		}
+		key = segments[keyIndex]
+	}
+
+	baseUrl := url.URL{
+		Scheme: parsedUrl.Scheme,
+		User:   parsedUrl.User,
+		Host:   parsedUrl.Host,
+	}
+	if keyIndex > 0 {
+		baseUrl.Path = "/" + strings.Join(segments[:keyIndex], "/")
+	}
+	return baseUrl.String(), key, nil
+}
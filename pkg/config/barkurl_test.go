@@ -0,0 +1,41 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseBarkUrl(t *testing.T) {
+	testCases := []struct {
+		barkUrl     string
+		barkBaseUrl string
+		key         string
+	}{
+		{"https://api.day.app/abcdefghijklmnopqrstuv/example", "https://api.day.app", "abcdefghijklmnopqrstuv"},
+		{"https://api.day.app/abcdefghijklmnopqrstuv/title/body?sound=bell", "https://api.day.app", "abcdefghijklmnopqrstuv"},
+		{" https://api.day.app/abcdefghijklmnopqrstuv \n", "https://api.day.app", "abcdefghijklmnopqrstuv"},
+		{"https://example.org/bark/abcdefghijklmnopqrstuv/%E6%B5%8B%E8%AF%95", "https://example.org/bark", "abcdefghijklmnopqrstuv"},
+		{"http://192.168.1.2:8080/my/bark/abcdefghijklmnopqrstuv/", "http://192.168.1.2:8080/my/bark", "abcdefghijklmnopqrstuv"},
+		{"https://bark.example.org/mykey", "https://bark.example.org", "mykey"},
+		{"https://api.day.app/push?device_key=mykey", "https://api.day.app", "mykey"},
+		{"https://example.org/bark/push?device_key=mykey&body=hi", "https://example.org/bark", "mykey"},
+	}
+	for _, testCase := range testCases {
+		barkBaseUrl, key, err := ParseBarkUrl(testCase.barkUrl)
+		assert.Nil(t, err, testCase.barkUrl)
+		assert.Equal(t, testCase.barkBaseUrl, barkBaseUrl, testCase.barkUrl)
+		assert.Equal(t, testCase.key, key, testCase.barkUrl)
+	}
+
+	for _, barkUrl := range []string{
+		"",
+		"api.day.app/abcdefghijklmnopqrstuv",
+		"ftp://api.day.app/abcdefghijklmnopqrstuv",
+		"https://api.day.app",
+		"https://api.day.app/push",
+		"https://example.org/bark/key/body",
+	} {
+		_, _, err := ParseBarkUrl(barkUrl)
+		assert.NotNil(t, err, barkUrl)
+	}
+}
@@ -1,26 +1,83 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/LGiki/bark-tray/assets"
+	"github.com/LGiki/bark-tray/pkg/clipwatcher"
+	"github.com/LGiki/bark-tray/pkg/history"
+	"github.com/LGiki/bark-tray/pkg/imagehost"
+	"github.com/LGiki/bark-tray/pkg/localapi"
 	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/LGiki/bark-tray/pkg/secret"
 	"github.com/LGiki/bark-tray/pkg/util"
+	"go.uber.org/zap"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+)
+
+const (
+	defaultMaxConcurrentPushes = 4
 )
 
 type Config struct {
-	Version     string    `json:"version"`
-	EnableLog   bool      `json:"enableLog"`
-	LogFilePath string    `json:"logFilePath"`
-	UserAgent   string    `json:"userAgent"`
-	Timeout     int       `json:"timeout"`
-	Devices     []*Device `json:"devices"`
+	Version     string `json:"version"`
+	EnableLog   bool   `json:"enableLog"`
+	LogFilePath string `json:"logFilePath"`
+	// LogRedaction is how the message bodies and the device keys are written to the log,
+	// defaults to logger.DefaultRedaction.
+	LogRedaction logger.Redaction `json:"logRedaction,omitempty"`
+	// Log is the setting of the level, the encoding and the rotation of the log file.
+	Log       *logger.Options `json:"log,omitempty"`
+	UserAgent string          `json:"userAgent"`
+	Timeout   int             `json:"timeout"`
+	// MaxConcurrentPushes is the maximum number of push requests
+	// sent at the same time when sending to multiple devices.
+	MaxConcurrentPushes int `json:"maxConcurrentPushes"`
+	// ImageHost is optional, images in the clipboard are uploaded to it
+	// and pushed as links if it is set.
+	ImageHost *imagehost.Host `json:"imageHost,omitempty"`
+	Devices   []*Device       `json:"devices"`
+	// Profiles are the push profiles listed in the "Send as..." menu.
+	Profiles []*Profile `json:"profiles"`
+	// Groups are the named sets of devices listed in the "Send to group..." menu.
+	Groups []*Group `json:"groups,omitempty"`
+	// WatchClipboard is the setting of the "Watch clipboard" mode, which
+	// sends every new text copied to the default device.
+	WatchClipboard *clipwatcher.Options `json:"watchClipboard,omitempty"`
+	// Hotkeys is optional, the global hotkeys are registered at startup if it is set.
+	Hotkeys *Hotkeys `json:"hotkeys,omitempty"`
+	// LocalApi is the setting of the local HTTP API for other applications to push through Bark Tray.
+	LocalApi *localapi.Options `json:"localApi,omitempty"`
+	// History is the setting of the history of the sent messages listed in the "Recent" menu.
+	History *history.Options `json:"history,omitempty"`
+	// SecretStore is the setting of where the device keys referenced by "keyring:NAME" are stored.
+	SecretStore *secret.Options `json:"secretStore,omitempty"`
+
+	// source is the content of the config file, used by Validate to locate the problems.
+	source       []byte
+	sourceFormat configFormat
+	// migratedSource is source converted to JSON and upgraded to CurrentVersion,
+	// which is what the config is decoded from.
+	migratedSource []byte
 }
 
+// configType is the Go type of the config file.
+var configType = reflect.TypeOf(Config{})
+
+// LoadConfig loads the config file in JSON, YAML or TOML according to its extension,
+// it is upgraded to CurrentVersion in memory if it is older, use MigrateConfigFile
+// to upgrade the file itself.
 func LoadConfig(configFilePath string) (*Config, error) {
 	var config Config
+	format, err := formatOf(configFilePath)
+	if err != nil {
+		return nil, err
+	}
 	configFile, err := os.Open(configFilePath)
 	if err != nil {
 		return nil, err
@@ -30,29 +87,96 @@ func LoadConfig(configFilePath string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = json.Unmarshal(configFileBytes, &config)
+	jsonBytes, err := toJson(format, configFileBytes)
 	if err != nil {
 		return nil, err
 	}
+	migratedBytes, _, _, err := migrateConfigData(jsonBytes)
+	if err != nil {
+		if format == formatJson {
+			return nil, withLineNumber(err, configFileBytes)
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(migratedBytes, &config)
+	if err != nil {
+		if format == formatJson {
+			return nil, withLineNumber(err, migratedBytes)
+		}
+		return nil, err
+	}
+	config.source = configFileBytes
+	config.sourceFormat = format
+	config.migratedSource = migratedBytes
 	return &config, nil
 }
 
+// withLineNumber adds the line number to the JSON syntax or type error err of data.
+func withLineNumber(err error, data []byte) error {
+	var offset int64
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxError):
+		offset = syntaxError.Offset
+	case errors.As(err, &typeError):
+		offset = typeError.Offset
+	default:
+		return err
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return fmt.Errorf("line %d: %s", bytes.Count(data[:offset], []byte("\n"))+1, err.Error())
+}
+
+// ResolveSecrets resolves the device keys that are secret references with resolver.
+// The keys that cannot be resolved are cleared, so that the devices are removed by
+// StripInvalidDevices, and ValidationErrors with the reasons are returned.
+func (c *Config) ResolveSecrets(resolver *secret.Resolver) error {
+	v := &validator{lines: sourceLinesOf(c.sourceFormat, c.source)}
+	for i, device := range c.Devices {
+		if err := device.ResolveKey(resolver); err != nil {
+			v.add(fmt.Sprintf("devices[%d].key", i), "%s", err.Error())
+			device.Key = ""
+		}
+	}
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return v.errors
+}
+
 // StripInvalidDevices removes all invalid devices in Config.Devices,
 // invalid device means:
 // 1. Device key is empty
 // 2. The BarkBaseUrl of Device is not a valid url
 // 3. Failed to strip query parameters using util.StripQueryParamFromUrl
+// 4. The Encryption of Device is set but invalid
+// 5. The PushOptions of Device is set but invalid
 func (c *Config) StripInvalidDevices() {
 	newDevices := make([]*Device, 0, len(c.Devices))
 	for i := 0; i < len(c.Devices); i++ {
 		device := c.Devices[i]
 		if device.Key == "" || !util.IsValidHttpUrl(device.BarkBaseUrl) {
-			logger.Warn(fmt.Sprintf("Invalid device: %s", device.Name))
+			logger.Warn("Invalid device", zap.String("device", device.Name))
 			continue
 		}
+		if device.Encryption != nil {
+			if err := device.Encryption.Validate(); err != nil {
+				logger.Warn("Invalid device", zap.String("device", device.Name), zap.Error(err))
+				continue
+			}
+		}
+		if device.PushOptions != nil {
+			if err := device.PushOptions.Validate(); err != nil {
+				logger.Warn("Invalid device", zap.String("device", device.Name), zap.Error(err))
+				continue
+			}
+		}
 		baseUrl, err := util.StripQueryParamFromUrl(device.BarkBaseUrl)
 		if err != nil {
-			logger.Warn(fmt.Sprintf("Invalid device: %s (%s)", device.Name, err.Error()))
+			logger.Warn("Invalid device", zap.String("device", device.Name), zap.Error(err))
 			continue
 		}
 		device.BarkBaseUrl = baseUrl
@@ -61,10 +185,39 @@ func (c *Config) StripInvalidDevices() {
 	c.Devices = newDevices
 }
 
+// StripInvalidProfiles removes all profiles in Config.Profiles that
+// fail to initialize with Profile.Init, e.g. due to an invalid template.
+func (c *Config) StripInvalidProfiles() {
+	newProfiles := make([]*Profile, 0, len(c.Profiles))
+	for _, profile := range c.Profiles {
+		if err := profile.Init(); err != nil {
+			logger.Warn("Invalid profile", zap.String("profile", profile.Name), zap.Error(err))
+			continue
+		}
+		newProfiles = append(newProfiles, profile)
+	}
+	c.Profiles = newProfiles
+}
+
+// CreateConfigFileTemplate writes the config file template to configFilePath,
+// creating its directory if it does not exist.
 func CreateConfigFileTemplate(configFilePath string) error {
+	if err := os.MkdirAll(filepath.Dir(configFilePath), 0755); err != nil {
+		return err
+	}
 	return os.WriteFile(configFilePath, assets.ConfigTemplate, 0644)
 }
 
+// GetDevice returns the device with the specified name, or nil if it does not exist.
+func (c *Config) GetDevice(name string) *Device {
+	for _, device := range c.Devices {
+		if device.Name == name {
+			return device
+		}
+	}
+	return nil
+}
+
 func (c *Config) GetDefaultDevice() *Device {
 	for _, device := range c.Devices {
 		if device.IsDefault {
@@ -74,6 +227,15 @@ func (c *Config) GetDefaultDevice() *Device {
 	return nil
 }
 
+// GetMaxConcurrentPushes returns Config.MaxConcurrentPushes,
+// or the default value if it is not set.
+func (c *Config) GetMaxConcurrentPushes() int {
+	if c.MaxConcurrentPushes <= 0 {
+		return defaultMaxConcurrentPushes
+	}
+	return c.MaxConcurrentPushes
+}
+
 func (c *Config) IsDefaultDeviceExist() bool {
 	return c.GetDefaultDevice() != nil
 }
@@ -1,26 +1,131 @@
 package config
 
 import (
-	"fmt"
 	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/LGiki/bark-tray/pkg/secret"
 	"github.com/LGiki/bark-tray/pkg/util"
+	"go.uber.org/zap"
 )
 
 type Device struct {
 	Name        string `json:"name"`
 	BarkBaseUrl string `json:"barkBaseUrl"`
-	Key         string `json:"key"`
-	IsDefault   bool   `json:"isDefault"`
+	// Key is either the device key or a secret reference to it, e.g. "keyring:MY_PHONE",
+	// the reference is replaced by the key when it is resolved by ResolveKey.
+	Key       string `json:"key"`
+	IsDefault bool   `json:"isDefault"`
+	// Encryption is optional, the push message will be sent
+	// as ciphertext if it is set, see also SecretGuardAllowIfEncrypted.
+	Encryption *bark.Encryption `json:"encryption,omitempty"`
+	// SecretGuard is what to do when a message contains secrets, defaults to SecretGuardConfirm.
+	SecretGuard SecretGuard `json:"secretGuard,omitempty"`
+	// PushOptions is optional, it is applied on every push to the device.
+	PushOptions *bark.PushOptions `json:"pushOptions,omitempty"`
+
+	// keyReference is the secret reference Key is resolved from.
+	keyReference string
+}
+
+// ResolveKey replaces Device.Key by the secret it references if it is a secret reference,
+// the reference is kept and written to the config file instead of the secret.
+func (d *Device) ResolveKey(resolver *secret.Resolver) error {
+	if !secret.IsReference(d.Key) {
+		return nil
+	}
+	key, err := resolver.Resolve(d.Key)
+	if err != nil {
+		return err
+	}
+	d.keyReference = d.Key
+	d.Key = key
+	return nil
+}
+
+// SetKey sets the key of the device to key, which may be a secret reference, and resolves it.
+func (d *Device) SetKey(key string, resolver *secret.Resolver) error {
+	d.Key = key
+	d.keyReference = ""
+	return d.ResolveKey(resolver)
+}
+
+// ConfigKey returns the key as it is in the config file, i.e. the secret reference if the key is resolved from one.
+func (d *Device) ConfigKey() string {
+	if d.keyReference != "" {
+		return d.keyReference
+	}
+	return d.Key
+}
+
+// NewTextPushRequest builds the push request of message without device key.
+func NewTextPushRequest(message string) *bark.PushRequest {
+	return &bark.PushRequest{
+		Body: message,
+		Url:  util.ExtractUrlFromText(message),
+	}
+}
+
+// imagePushRequestBody is the body of the push requests built by NewImagePushRequest.
+const imagePushRequestBody = "[Image]"
+
+// NewImagePushRequest builds the push request of an image at imageUrl without device key,
+// clicking the notification opens the image.
+func NewImagePushRequest(imageUrl string) *bark.PushRequest {
+	return &bark.PushRequest{
+		Body: imagePushRequestBody,
+		Url:  imageUrl,
+		Icon: imageUrl,
+	}
+}
+
+// IsImagePushRequest reports whether pushRequest is built by NewImagePushRequest,
+// whose body is only a placeholder for the image at its url.
+func IsImagePushRequest(pushRequest *bark.PushRequest) bool {
+	return pushRequest.Body == imagePushRequestBody && pushRequest.Url != ""
 }
 
 func (d *Device) PushTextMessage(message string) error {
-	messageUrl := util.ExtractUrlFromText(message)
-	barkPushResponse, err := bark.PushTextMessage(d.BarkBaseUrl, d.Key, message, messageUrl)
+	return d.Push(NewTextPushRequest(message))
+}
+
+// Push sends pushRequest to the device, the device key of pushRequest
+// will be set to Device.Key and Device.PushOptions will be applied.
+func (d *Device) Push(pushRequest *bark.PushRequest) error {
+	_, err := d.PushWithResponse(pushRequest)
+	return err
+}
+
+// PushWithResponse is Push that also returns the response of the Bark server if it succeeds.
+func (d *Device) PushWithResponse(pushRequest *bark.PushRequest) (*bark.PushResponse, error) {
+	devicePushRequest := d.applyPushOptions(pushRequest)
+	devicePushRequest.DeviceKey = d.Key
+	barkPushResponse, err := bark.Push(d.BarkBaseUrl, devicePushRequest, d.Encryption)
+	if err = checkPushResponse(barkPushResponse, err); err != nil {
+		return nil, err
+	}
+	return barkPushResponse, nil
+}
+
+// LogFields returns the log fields of the device, the key is redacted by logger.Key.
+func (d *Device) LogFields() []zap.Field {
+	return []zap.Field{zap.String("device", d.Name), zap.String("key", logger.Key(d.Key))}
+}
+
+// applyPushOptions returns a copy of pushRequest with Device.PushOptions applied.
+func (d *Device) applyPushOptions(pushRequest *bark.PushRequest) *bark.PushRequest {
+	devicePushRequest := *pushRequest
+	if d.PushOptions != nil {
+		d.PushOptions.Apply(&devicePushRequest)
+	}
+	return &devicePushRequest
+}
+
+func checkPushResponse(barkPushResponse *bark.PushResponse, err error) error {
 	if err != nil {
 		return err
 	}
 	if barkPushResponse.Code != 200 {
-		return fmt.Errorf(barkPushResponse.Message)
+		return &bark.PushError{Code: barkPushResponse.Code, Message: barkPushResponse.Message}
 	}
 	return nil
 }
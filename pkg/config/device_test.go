@@ -0,0 +1,56 @@
+package config
+
+import (
+	"errors"
+	"github.com/LGiki/bark-tray/pkg/secret"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("BARK_TEST_KEY", "key2")
+	configFilePath := filepath.Join(t.TempDir(), "config.json")
+	assert.Nil(t, os.WriteFile(configFilePath, []byte(`{
+  "version": "1.1.0",
+  "timeout": 5,
+  "devices": [
+    {"name": "iPhone", "barkBaseUrl": "https://api.day.app", "key": "key1"},
+    {"name": "iPad", "barkBaseUrl": "https://api.day.app", "key": "env:BARK_TEST_KEY"},
+    {"name": "Mac", "barkBaseUrl": "https://api.day.app", "key": "env:BARK_TEST_MISSING"}
+  ]
+}
+`), 0600))
+	config, err := LoadConfig(configFilePath)
+	assert.Nil(t, err)
+	resolver := &secret.Resolver{BaseDir: filepath.Dir(configFilePath)}
+	err = config.ResolveSecrets(resolver)
+	var validationErrors ValidationErrors
+	assert.True(t, errors.As(err, &validationErrors))
+	assert.Len(t, validationErrors, 1)
+	assert.Equal(t, "devices[2].key (line 7): environment variable 'BARK_TEST_MISSING' is not set", validationErrors[0].Error())
+	assert.Equal(t, "key1", config.Devices[0].Key)
+	assert.Equal(t, "key2", config.Devices[1].Key)
+	assert.Equal(t, "env:BARK_TEST_KEY", config.Devices[1].ConfigKey())
+	assert.Equal(t, "", config.Devices[2].Key)
+
+	// The reference is written back instead of the key
+	device := *config.Devices[1]
+	device.IsDefault = true
+	assert.Nil(t, UpdateDevice(configFilePath, "iPad", &device))
+	config, err = LoadConfig(configFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "env:BARK_TEST_KEY", config.Devices[1].Key)
+	assert.True(t, config.Devices[1].IsDefault)
+
+	// A new key replaces the reference
+	assert.Nil(t, device.SetKey("key3", resolver))
+	assert.Equal(t, "key3", device.ConfigKey())
+}
+
+func TestIsImagePushRequest(t *testing.T) {
+	assert.True(t, IsImagePushRequest(NewImagePushRequest("https://example.com/image.png")))
+	assert.False(t, IsImagePushRequest(NewTextPushRequest("[Image]")))
+	assert.False(t, IsImagePushRequest(NewTextPushRequest("https://example.com/image.png")))
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+)
+
+// The functions in this file edit the devices in the config file in place,
+// the other fields, including those unknown to Config, are kept as they are.
+
+// AddDevice appends device to the config file, if device is the default device,
+// the other devices are no longer the default device.
+func AddDevice(configFilePath string, device *Device) error {
+	return editDevices(configFilePath, func(root *jsonObject, devices []*jsonObject) ([]*jsonObject, error) {
+		if findDeviceObject(devices, device.Name) >= 0 {
+			return nil, fmt.Errorf("device '%s' already exists", device.Name)
+		}
+		deviceObject := newJsonObject()
+		if err := setDeviceObject(deviceObject, device); err != nil {
+			return nil, err
+		}
+		if device.Encryption != nil {
+			if err := deviceObject.Set("encryption", device.Encryption); err != nil {
+				return nil, err
+			}
+		}
+		if device.PushOptions != nil {
+			if err := deviceObject.Set("pushOptions", device.PushOptions); err != nil {
+				return nil, err
+			}
+		}
+		devices = append(devices, deviceObject)
+		if device.IsDefault {
+			return devices, setDefaultDeviceObject(devices, device.Name)
+		}
+		return devices, nil
+	})
+}
+
+// UpdateDevice replaces the name, the url, the key and the default flag of the device
+// with name by those of device, the other fields of the device are kept.
+// If the device is renamed, the groups and the hotkeys of it are updated to the new name.
+func UpdateDevice(configFilePath string, name string, device *Device) error {
+	return editDevices(configFilePath, func(root *jsonObject, devices []*jsonObject) ([]*jsonObject, error) {
+		index := findDeviceObject(devices, name)
+		if index < 0 {
+			return nil, fmt.Errorf("device '%s' not found", name)
+		}
+		if device.Name != name && findDeviceObject(devices, device.Name) >= 0 {
+			return nil, fmt.Errorf("device '%s' already exists", device.Name)
+		}
+		if err := setDeviceObject(devices[index], device); err != nil {
+			return nil, err
+		}
+		if device.Name != name {
+			if err := renameDeviceReferences(root, name, device.Name); err != nil {
+				return nil, err
+			}
+		}
+		if device.IsDefault {
+			return devices, setDefaultDeviceObject(devices, device.Name)
+		}
+		return devices, nil
+	})
+}
+
+// RemoveDevice removes the device with name from the config file, as well as
+// from the groups and the hotkeys, the groups left without any device are removed.
+func RemoveDevice(configFilePath string, name string) error {
+	return editDevices(configFilePath, func(root *jsonObject, devices []*jsonObject) ([]*jsonObject, error) {
+		index := findDeviceObject(devices, name)
+		if index < 0 {
+			return nil, fmt.Errorf("device '%s' not found", name)
+		}
+		if err := renameDeviceReferences(root, name, ""); err != nil {
+			return nil, err
+		}
+		return append(devices[:index], devices[index+1:]...), nil
+	})
+}
+
+// SetDefaultDevice makes the device with name the only default device in the config file.
+func SetDefaultDevice(configFilePath string, name string) error {
+	return editDevices(configFilePath, func(root *jsonObject, devices []*jsonObject) ([]*jsonObject, error) {
+		if findDeviceObject(devices, name) < 0 {
+			return nil, fmt.Errorf("device '%s' not found", name)
+		}
+		return devices, setDefaultDeviceObject(devices, name)
+	})
+}
+
+// editDevices reads the devices in the config file, edits them with edit, which may
+// also edit the other fields in root, and writes the config file back atomically.
+func editDevices(configFilePath string, edit func(root *jsonObject, devices []*jsonObject) ([]*jsonObject, error)) error {
+	root, original, err := readConfigObject(configFilePath)
+	if err != nil {
+		return err
+	}
+	var devices []*jsonObject
+	if err = root.Get("devices", &devices); err != nil {
+		return fmt.Errorf("invalid devices: %s", err.Error())
+	}
+	devices, err = edit(root, devices)
+	if err != nil {
+		return err
+	}
+	if devices == nil {
+		devices = []*jsonObject{}
+	}
+	if err = root.Set("devices", devices); err != nil {
+		return err
+	}
+	return writeConfigObject(configFilePath, root, original)
+}
+
+func findDeviceObject(devices []*jsonObject, name string) int {
+	for i, deviceObject := range devices {
+		var deviceName string
+		if err := deviceObject.Get("name", &deviceName); err == nil && deviceName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func setDeviceObject(deviceObject *jsonObject, device *Device) error {
+	if err := deviceObject.Set("name", device.Name); err != nil {
+		return err
+	}
+	if err := deviceObject.Set("barkBaseUrl", device.BarkBaseUrl); err != nil {
+		return err
+	}
+	// The secret reference is written instead of the key resolved from it
+	if err := deviceObject.Set("key", device.ConfigKey()); err != nil {
+		return err
+	}
+	return deviceObject.Set("isDefault", device.IsDefault)
+}
+
+func setDefaultDeviceObject(devices []*jsonObject, name string) error {
+	defaultIndex := findDeviceObject(devices, name)
+	for i, deviceObject := range devices {
+		if err := deviceObject.Set("isDefault", i == defaultIndex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameDeviceReferences replaces the device name oldName in the groups and the hotkeys
+// in root with newName, or removes it if newName is empty.
+func renameDeviceReferences(root *jsonObject, oldName string, newName string) error {
+	var groups []*jsonObject
+	if err := root.Get("groups", &groups); err != nil {
+		return fmt.Errorf("invalid groups: %s", err.Error())
+	}
+	if groups != nil {
+		newGroups := make([]*jsonObject, 0, len(groups))
+		for _, groupObject := range groups {
+			var deviceNames []string
+			if err := groupObject.Get("devices", &deviceNames); err != nil {
+				return fmt.Errorf("invalid groups: %s", err.Error())
+			}
+			newDeviceNames := make([]string, 0, len(deviceNames))
+			for _, deviceName := range deviceNames {
+				if deviceName != oldName {
+					newDeviceNames = append(newDeviceNames, deviceName)
+				} else if newName != "" {
+					newDeviceNames = append(newDeviceNames, newName)
+				}
+			}
+			if len(newDeviceNames) == 0 && len(deviceNames) > 0 {
+				// The removed device is the only device of the group
+				continue
+			}
+			if err := groupObject.Set("devices", newDeviceNames); err != nil {
+				return err
+			}
+			newGroups = append(newGroups, groupObject)
+		}
+		if err := root.Set("groups", newGroups); err != nil {
+			return err
+		}
+	}
+
+	var hotkeysObject *jsonObject
+	if err := root.Get("hotkeys", &hotkeysObject); err != nil {
+		return fmt.Errorf("invalid hotkeys: %s", err.Error())
+	}
+	if hotkeysObject == nil {
+		return nil
+	}
+	var deviceHotkeys *jsonObject
+	if err := hotkeysObject.Get("devices", &deviceHotkeys); err != nil {
+		return fmt.Errorf("invalid hotkeys: %s", err.Error())
+	}
+	if deviceHotkeys == nil || !deviceHotkeys.Has(oldName) {
+		return nil
+	}
+	if newName == "" {
+		deviceHotkeys.Delete(oldName)
+	} else {
+		deviceHotkeys.Rename(oldName, newName)
+	}
+	if err := hotkeysObject.Set("devices", deviceHotkeys); err != nil {
+		return err
+	}
+	return root.Set("hotkeys", hotkeysObject)
+}
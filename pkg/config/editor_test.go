@@ -0,0 +1,146 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const editorTestConfig = `{
+  "version": "1.0.3",
+  "futureField": {"b": 1, "a": [true]},
+  "timeout": 5,
+  "devices": [
+    {
+      "name": "iPhone",
+      "barkBaseUrl": "https://api.day.app",
+      "key": "key1",
+      "isDefault": true,
+      "encryption": {"algorithm": "AES256", "mode": "CBC", "key": "12345678901234567890123456789012", "iv": "1234567890123456"},
+      "unknown": "kept"
+    }
+  ]
+}
+`
+
+func writeEditorTestConfig(t *testing.T) string {
+	configFilePath := filepath.Join(t.TempDir(), "config.json")
+	assert.Nil(t, os.WriteFile(configFilePath, []byte(editorTestConfig), 0600))
+	return configFilePath
+}
+
+func TestAddDevice(t *testing.T) {
+	configFilePath := writeEditorTestConfig(t)
+	assert.Nil(t, AddDevice(configFilePath, &Device{Name: "iPad", BarkBaseUrl: "https://bark.example.org", Key: "key2", IsDefault: true}))
+	assert.NotNil(t, AddDevice(configFilePath, &Device{Name: "iPad", BarkBaseUrl: "https://api.day.app", Key: "key3"}))
+
+	configFileBytes, err := os.ReadFile(configFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, `{
+  "version": "1.0.3",
+  "futureField": {
+    "b": 1,
+    "a": [
+      true
+    ]
+  },
+  "timeout": 5,
+  "devices": [
+    {
+      "name": "iPhone",
+      "barkBaseUrl": "https://api.day.app",
+      "key": "key1",
+      "isDefault": false,
+      "encryption": {
+        "algorithm": "AES256",
+        "mode": "CBC",
+        "key": "12345678901234567890123456789012",
+        "iv": "1234567890123456"
+      },
+      "unknown": "kept"
+    },
+    {
+      "name": "iPad",
+      "barkBaseUrl": "https://bark.example.org",
+      "key": "key2",
+      "isDefault": true
+    }
+  ]
+}
+`, string(configFileBytes))
+
+	fileInfo, err := os.Stat(configFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+}
+
+func TestUpdateDevice(t *testing.T) {
+	configFilePath := writeEditorTestConfig(t)
+	assert.Nil(t, UpdateDevice(configFilePath, "iPhone", &Device{Name: "My iPhone", BarkBaseUrl: "https://bark.example.org", Key: "key2"}))
+	assert.NotNil(t, UpdateDevice(configFilePath, "iPhone", &Device{Name: "iPhone"}))
+
+	config, err := LoadConfig(configFilePath)
+	assert.Nil(t, err)
+	assert.Len(t, config.Devices, 1)
+	device := config.Devices[0]
+	assert.Equal(t, "My iPhone", device.Name)
+	assert.Equal(t, "https://bark.example.org", device.BarkBaseUrl)
+	assert.Equal(t, "key2", device.Key)
+	assert.False(t, device.IsDefault)
+	assert.NotNil(t, device.Encryption)
+}
+
+func TestRemoveAndSetDefaultDevice(t *testing.T) {
+	configFilePath := writeEditorTestConfig(t)
+	assert.Nil(t, AddDevice(configFilePath, &Device{Name: "iPad", BarkBaseUrl: "https://api.day.app", Key: "key2"}))
+	assert.Nil(t, SetDefaultDevice(configFilePath, "iPad"))
+	config, err := LoadConfig(configFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "iPad", config.GetDefaultDevice().Name)
+	assert.False(t, config.GetDevice("iPhone").IsDefault)
+
+	assert.Nil(t, RemoveDevice(configFilePath, "iPad"))
+	assert.NotNil(t, RemoveDevice(configFilePath, "iPad"))
+	assert.NotNil(t, SetDefaultDevice(configFilePath, "iPad"))
+	config, err = LoadConfig(configFilePath)
+	assert.Nil(t, err)
+	assert.Len(t, config.Devices, 1)
+	assert.Nil(t, config.GetDefaultDevice())
+}
+
+func TestDeviceReferences(t *testing.T) {
+	configFilePath := filepath.Join(t.TempDir(), "config.json")
+	assert.Nil(t, os.WriteFile(configFilePath, []byte(`{
+  "version": "1.1.0",
+  "timeout": 5,
+  "devices": [
+    {"name": "iPhone", "barkBaseUrl": "https://api.day.app", "key": "key1", "isDefault": true},
+    {"name": "iPad", "barkBaseUrl": "https://api.day.app", "key": "key2"}
+  ],
+  "groups": [
+    {"name": "All", "devices": ["iPhone", "iPad"]},
+    {"name": "Tablets", "devices": ["iPad"]}
+  ],
+  "hotkeys": {"allDevices": "Ctrl+Alt+A", "devices": {"iPad": "Ctrl+Alt+2", "iPhone": "Ctrl+Alt+1"}}
+}`), 0600))
+
+	assert.Nil(t, UpdateDevice(configFilePath, "iPad", &Device{Name: "My iPad", BarkBaseUrl: "https://api.day.app", Key: "key2"}))
+	config, err := LoadConfig(configFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"iPhone", "My iPad"}, config.GetGroup("All").Devices)
+	assert.Equal(t, []string{"My iPad"}, config.GetGroup("Tablets").Devices)
+	assert.Equal(t, map[string]string{"My iPad": "Ctrl+Alt+2", "iPhone": "Ctrl+Alt+1"}, config.Hotkeys.Devices)
+	config.LogFilePath = filepath.Join(t.TempDir(), "bark-tray.log")
+	assert.Nil(t, config.Validate())
+
+	assert.Nil(t, RemoveDevice(configFilePath, "My iPad"))
+	config, err = LoadConfig(configFilePath)
+	assert.Nil(t, err)
+	assert.Len(t, config.Groups, 1)
+	assert.Equal(t, []string{"iPhone"}, config.GetGroup("All").Devices)
+	assert.Equal(t, map[string]string{"iPhone": "Ctrl+Alt+1"}, config.Hotkeys.Devices)
+	assert.Equal(t, "Ctrl+Alt+A", config.Hotkeys.AllDevices)
+	config.LogFilePath = filepath.Join(t.TempDir(), "bark-tray.log")
+	assert.Nil(t, config.Validate())
+}
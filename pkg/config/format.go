@@ -0,0 +1,178 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configFormat is the format of the config file, which is determined by its extension.
+// The YAML and TOML config files are converted to JSON when they are read, so the
+// keys and the values in them are the same as those in the JSON config file.
+type configFormat string
+
+const (
+	formatJson configFormat = "json"
+	formatYaml configFormat = "yaml"
+	formatToml configFormat = "toml"
+)
+
+// formatOf returns the format of the config file at filePath.
+func formatOf(filePath string) (configFormat, error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		return formatJson, nil
+	case ".yaml", ".yml":
+		return formatYaml, nil
+	case ".toml":
+		return formatToml, nil
+	}
+	return "", fmt.Errorf("unsupported config file format '%s', the extension must be .json, .yaml, .yml or .toml", filepath.Ext(filePath))
+}
+
+// toJson converts data in format to JSON, keeping the order of the keys.
+func toJson(format configFormat, data []byte) ([]byte, error) {
+	switch format {
+	case formatYaml:
+		node, err := yamlToNode(data)
+		if err != nil {
+			return nil, err
+		}
+		return nodeToJson(node)
+	case formatToml:
+		node, err := tomlToNode(data)
+		if err != nil {
+			return nil, err
+		}
+		return nodeToJson(node)
+	}
+	return data, nil
+}
+
+// fromJson converts the JSON data to format. For YAML, the comments in original,
+// which is the content of the file being overwritten, are kept if it is not nil.
+func fromJson(format configFormat, data []byte, original []byte) ([]byte, error) {
+	if format == formatJson {
+		var buffer bytes.Buffer
+		if err := json.Indent(&buffer, data, "", "  "); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte('\n')
+		return buffer.Bytes(), nil
+	}
+	node, err := jsonToNode(data)
+	if err != nil {
+		return nil, err
+	}
+	if format == formatToml {
+		return nodeToToml(node)
+	}
+	if original != nil {
+		if originalNode, err := yamlToNode(original); err == nil {
+			copyYamlComments(node, originalNode)
+		}
+	}
+	return nodeToYaml(node)
+}
+
+// sourceLinesOf returns the line numbers of the values in the config file content data,
+// or nil if they are unknown.
+func sourceLinesOf(format configFormat, data []byte) sourceLines {
+	switch format {
+	case formatJson:
+		if source, err := parseJsonSource(data, configType); err == nil {
+			return source.lines
+		}
+	case formatYaml:
+		if node, err := yamlToNode(data); err == nil {
+			lines := make(sourceLines)
+			yamlLines(node, "", lines)
+			return lines
+		}
+	}
+	return nil
+}
+
+// readConfigObject reads the config file as a jsonObject, the content of the file is returned as original.
+func readConfigObject(configFilePath string) (root *jsonObject, original []byte, err error) {
+	format, err := formatOf(configFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	original, err = os.ReadFile(configFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := toJson(format, original)
+	if err != nil {
+		return nil, nil, err
+	}
+	root = newJsonObject()
+	if err = json.Unmarshal(data, root); err != nil {
+		return nil, nil, err
+	}
+	return root, original, nil
+}
+
+// writeConfigObject writes root to the config file atomically in the format of the file.
+// original is the content of the file being overwritten, see fromJson.
+func writeConfigObject(configFilePath string, root *jsonObject, original []byte) error {
+	format, err := formatOf(configFilePath)
+	if err != nil {
+		return err
+	}
+	fileInfo, err := os.Stat(configFilePath)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(root)
+	if err != nil {
+		return err
+	}
+	return writeConfigData(configFilePath, format, data, original, fileInfo.Mode().Perm())
+}
+
+// writeConfigData converts the JSON data to format and writes it to configFilePath atomically.
+func writeConfigData(configFilePath string, format configFormat, data []byte, original []byte, perm os.FileMode) error {
+	data, err := fromJson(format, data, original)
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(configFilePath, data, perm)
+}
+
+// ConvertConfigFile converts the config file at sourcePath to the format of destinationPath,
+// both formats are determined by the extensions. The keys and the values are kept as they
+// are, except that the null values are dropped when converting to TOML, which has no null.
+// destinationPath must not exist.
+func ConvertConfigFile(sourcePath string, destinationPath string) error {
+	sourceFormat, err := formatOf(sourcePath)
+	if err != nil {
+		return err
+	}
+	destinationFormat, err := formatOf(destinationPath)
+	if err != nil {
+		return err
+	}
+	if util.IsFileExists(destinationPath) {
+		return fmt.Errorf("%s already exists", destinationPath)
+	}
+	fileInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+	sourceBytes, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	data, err := toJson(sourceFormat, sourceBytes)
+	if err != nil {
+		return err
+	}
+	// The permission is kept as the config file contains the device keys
+	return writeConfigData(destinationPath, destinationFormat, data, nil, fileInfo.Mode().Perm())
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const formatTestJson = `{
+  "version": "1.1.0",
+  "enableLog": false,
+  "logFilePath": "bark-tray.log",
+  "userAgent": "Bark Tray/1.0",
+  "timeout": 5,
+  "maxConcurrentPushes": 4,
+  "devices": [
+    {
+      "name": "iPhone",
+      "barkBaseUrl": "https://api.day.app",
+      "key": "123",
+      "isDefault": true,
+      "pushOptions": {
+        "sound": "bell",
+        "level": "timeSensitive"
+      }
+    },
+    {
+      "name": "iPad",
+      "barkBaseUrl": "https://api.day.app",
+      "key": "true",
+      "isDefault": false
+    }
+  ],
+  "profiles": [],
+  "hotkeys": {
+    "defaultDevice": "Ctrl+Alt+B",
+    "devices": {
+      "iPad": "Ctrl+Alt+I"
+    }
+  }
+}
+`
+
+const formatTestYaml = `# Bark Tray
+version: 1.1.0
+enableLog: false
+logFilePath: bark-tray.log
+userAgent: Bark Tray/1.0
+timeout: 5
+maxConcurrentPushes: 4
+devices:
+  - name: iPhone
+    barkBaseUrl: https://api.day.app
+    key: "123"
+    isDefault: true
+    pushOptions:
+      sound: bell
+      level: timeSensitive
+  # The tablet
+  - name: iPad
+    barkBaseUrl: https://api.day.app
+    key: "true"
+    isDefault: false
+profiles: []
+hotkeys:
+  defaultDevice: Ctrl+Alt+B
+  devices:
+    iPad: Ctrl+Alt+I
+`
+
+const formatTestToml = `version = "1.1.0"
+enableLog = false
+logFilePath = "bark-tray.log"
+userAgent = "Bark Tray/1.0"
+timeout = 5
+maxConcurrentPushes = 4
+profiles = []
+
+[[devices]]
+name = "iPhone"
+barkBaseUrl = "https://api.day.app"
+key = "123"
+isDefault = true
+
+[devices.pushOptions]
+sound = "bell"
+level = "timeSensitive"
+
+[[devices]]
+name = "iPad"
+barkBaseUrl = "https://api.day.app"
+key = "true"
+isDefault = false
+
+[hotkeys]
+defaultDevice = "Ctrl+Alt+B"
+
+[hotkeys.devices]
+iPad = "Ctrl+Alt+I"
+`
+
+func TestLoadConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+	var configs []*Config
+	for fileName, content := range map[string]string{
+		"config.json": formatTestJson,
+		"config.yaml": formatTestYaml,
+		"config.toml": formatTestToml,
+	} {
+		configFilePath := filepath.Join(dir, fileName)
+		assert.Nil(t, os.WriteFile(configFilePath, []byte(content), 0644))
+		config, err := LoadConfig(configFilePath)
+		assert.Nil(t, err, fileName)
+		assert.Nil(t, config.Validate(), fileName)
+		config.source = nil
+		config.sourceFormat = ""
+		config.migratedSource = nil
+		configs = append(configs, config)
+	}
+	assert.Equal(t, configs[0], configs[1])
+	assert.Equal(t, configs[0], configs[2])
+	assert.Equal(t, "true", configs[0].Devices[1].Key)
+
+	_, err := LoadConfig(filepath.Join(dir, "config.ini"))
+	assert.NotNil(t, err)
+}
+
+func TestValidateYamlLineNumbers(t *testing.T) {
+	configFilePath := filepath.Join(t.TempDir(), "config.yml")
+	assert.Nil(t, os.WriteFile(configFilePath, []byte(`version: 1.1.0
+timeout: 0
+devices:
+  - name: iPhone
+    barkBaseUrl: ftp://api.day.app
+    key: abc
+    colour: red
+`), 0644))
+	config, err := LoadConfig(configFilePath)
+	assert.Nil(t, err)
+	err = config.Validate()
+	var validationErrors ValidationErrors
+	assert.True(t, errors.As(err, &validationErrors))
+	var messages []string
+	for _, validationError := range validationErrors {
+		messages = append(messages, validationError.Error())
+	}
+	assert.Equal(t, []string{
+		"timeout (line 2): must be between 1 and 300 seconds, got 0",
+		"devices[0].barkBaseUrl (line 5): 'ftp://api.day.app' is not a valid http or https url",
+		"devices[0].colour (line 7): unknown field",
+	}, messages)
+}
+
+func TestConvertConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	jsonFilePath := filepath.Join(dir, "config.json")
+	assert.Nil(t, os.WriteFile(jsonFilePath, []byte(formatTestJson), 0600))
+
+	// JSON -> YAML -> TOML -> JSON keeps all the values
+	yamlFilePath := filepath.Join(dir, "config.yaml")
+	tomlFilePath := filepath.Join(dir, "config.toml")
+	convertedFilePath := filepath.Join(dir, "converted.json")
+	assert.Nil(t, ConvertConfigFile(jsonFilePath, yamlFilePath))
+	assert.Nil(t, ConvertConfigFile(yamlFilePath, tomlFilePath))
+	assert.Nil(t, ConvertConfigFile(tomlFilePath, convertedFilePath))
+	yamlBytes, err := os.ReadFile(yamlFilePath)
+	assert.Nil(t, err)
+	expectedYaml := strings.NewReplacer("# Bark Tray\n", "", "  # The tablet\n", "").Replace(formatTestYaml)
+	assert.Equal(t, expectedYaml, string(yamlBytes), "the keys are in the original order")
+	var expected, actual interface{}
+	convertedBytes, err := os.ReadFile(convertedFilePath)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal([]byte(formatTestJson), &expected))
+	assert.Nil(t, json.Unmarshal(convertedBytes, &actual))
+	assert.Equal(t, expected, actual)
+
+	// The permission of the config file is kept
+	fileInfo, err := os.Stat(tomlFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+
+	assert.NotNil(t, ConvertConfigFile(jsonFilePath, yamlFilePath), "the destination exists")
+	assert.NotNil(t, ConvertConfigFile(jsonFilePath, filepath.Join(dir, "config.ini")))
+}
+
+func TestEditYamlConfigFile(t *testing.T) {
+	configFilePath := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(t, os.WriteFile(configFilePath, []byte(formatTestYaml), 0644))
+	assert.Nil(t, RemoveDevice(configFilePath, "iPhone"))
+	configFileBytes, err := os.ReadFile(configFilePath)
+	assert.Nil(t, err)
+	// The comments are kept
+	assert.Equal(t, `# Bark Tray
+version: 1.1.0
+enableLog: false
+logFilePath: bark-tray.log
+userAgent: Bark Tray/1.0
+timeout: 5
+maxConcurrentPushes: 4
+devices:
+  # The tablet
+  - name: iPad
+    barkBaseUrl: https://api.day.app
+    key: "true"
+    isDefault: false
+profiles: []
+hotkeys:
+  defaultDevice: Ctrl+Alt+B
+  devices:
+    iPad: Ctrl+Alt+I
+`, string(configFileBytes))
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Group is a named set of devices that are sent to together, e.g. "Family phones".
+type Group struct {
+	Name string `json:"name"`
+	// Devices is the names of the devices in the group.
+	Devices []string `json:"devices"`
+
+	// devices is the devices in Config.Devices with the names in Devices, set by StripInvalidGroups.
+	devices []*Device
+}
+
+// GetDevices returns the devices in the group.
+func (g *Group) GetDevices() []*Device {
+	return g.devices
+}
+
+// GetGroup returns the group with the specified name, or nil if it does not exist.
+func (c *Config) GetGroup(name string) *Group {
+	for _, group := range c.Groups {
+		if group.Name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// StripInvalidGroups resolves the device names of each group in Config.Groups, the names of
+// the devices that do not exist are ignored, and the groups without any device are removed.
+// It must be called after StripInvalidDevices.
+func (c *Config) StripInvalidGroups() {
+	newGroups := make([]*Group, 0, len(c.Groups))
+	for _, group := range c.Groups {
+		group.devices = nil
+		added := make(map[string]bool)
+		for _, deviceName := range group.Devices {
+			device := c.GetDevice(deviceName)
+			if device == nil {
+				logger.Warn("Device of group does not exist", zap.String("group", group.Name), zap.String("device", deviceName))
+				continue
+			}
+			if !added[deviceName] {
+				group.devices = append(group.devices, device)
+				added[deviceName] = true
+			}
+		}
+		if group.Name == "" || len(group.devices) == 0 {
+			logger.Warn("Invalid group without device", zap.String("group", group.Name))
+			continue
+		}
+		newGroups = append(newGroups, group)
+	}
+	c.Groups = newGroups
+}
+
+func (c *Config) validateGroups(v *validator) {
+	groupNames := make(map[string]int)
+	for i, group := range c.Groups {
+		path := fmt.Sprintf("groups[%d]", i)
+		if group.Name == "" {
+			v.add(path+".name", "group name is empty")
+		} else if index, ok := groupNames[group.Name]; ok {
+			v.add(path+".name", "duplicate group name '%s', it is also used by groups[%d]", group.Name, index)
+		} else {
+			groupNames[group.Name] = i
+		}
+		if len(group.Devices) == 0 {
+			v.add(path+".devices", "group '%s' has no device", group.Name)
+		}
+		deviceNames := make(map[string]int)
+		for j, deviceName := range group.Devices {
+			devicePath := fmt.Sprintf("%s.devices[%d]", path, j)
+			if c.GetDevice(deviceName) == nil {
+				v.add(devicePath, "device '%s' does not exist", deviceName)
+			} else if index, ok := deviceNames[deviceName]; ok {
+				v.add(devicePath, "device '%s' is already in the group as devices[%d]", deviceName, index)
+			} else {
+				deviceNames[deviceName] = j
+			}
+		}
+	}
+}
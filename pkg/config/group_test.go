@@ -0,0 +1,25 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStripInvalidGroups(t *testing.T) {
+	iPhone := &Device{Name: "iPhone"}
+	iPad := &Device{Name: "iPad"}
+	config := &Config{
+		Devices: []*Device{iPhone, iPad},
+		Groups: []*Group{
+			{Name: "Family", Devices: []string{"iPhone", "Mac", "iPad", "iPhone"}},
+			{Name: "Work", Devices: []string{"Mac"}},
+			{Name: "Empty"},
+		},
+	}
+	config.StripInvalidGroups()
+	assert.Len(t, config.Groups, 1)
+	group := config.GetGroup("Family")
+	assert.NotNil(t, group)
+	assert.Equal(t, []*Device{iPhone, iPad}, group.GetDevices())
+	assert.Nil(t, config.GetGroup("Work"))
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/sensitive"
+)
+
+// SecretGuard is what to do when a message to a device contains secrets,
+// such as private keys and API keys.
+type SecretGuard string
+
+const (
+	// SecretGuardConfirm asks the user whether to send the message, defaults to it.
+	// The message is not sent if the user cannot be asked, e.g. from the command line.
+	SecretGuardConfirm SecretGuard = "confirm"
+	// SecretGuardAllow sends the message, e.g. to a self-hosted Bark server.
+	SecretGuardAllow SecretGuard = "allow"
+	// SecretGuardBlock never sends the message.
+	SecretGuardBlock SecretGuard = "block"
+	// SecretGuardAllowIfEncrypted sends the message only if it is encrypted, i.e. the device
+	// has Device.Encryption, and blocks it otherwise. It does not encrypt the message itself,
+	// all the messages to a device with Device.Encryption are encrypted as usual.
+	SecretGuardAllowIfEncrypted SecretGuard = "encrypt"
+)
+
+func (g SecretGuard) IsValid() bool {
+	switch g {
+	case SecretGuardConfirm, SecretGuardAllow, SecretGuardBlock, SecretGuardAllowIfEncrypted:
+		return true
+	}
+	return false
+}
+
+// GetSecretGuard returns Device.SecretGuard, or SecretGuardConfirm if it is not set.
+func (d *Device) GetSecretGuard() SecretGuard {
+	if d.SecretGuard == "" {
+		return SecretGuardConfirm
+	}
+	return d.SecretGuard
+}
+
+// SecretCheck is the result of inspecting a message for secrets before sending it to devices.
+type SecretCheck struct {
+	// Kinds is the kinds of the secrets found, it is empty if there is none.
+	Kinds []sensitive.Kind
+	// Allowed is the devices the message can be sent to.
+	Allowed []*Device
+	// Unconfirmed is the devices the message can be sent to only if the user confirms.
+	Unconfirmed []*Device
+	// Blocked is the devices the message must not be sent to.
+	Blocked []*Device
+}
+
+// CheckSecrets inspects the text fields of pushRequest for secrets, and decides
+// whether it can be sent to each device according to its SecretGuard.
+func CheckSecrets(devices []*Device, pushRequest *bark.PushRequest) *SecretCheck {
+	check := &SecretCheck{Kinds: pushRequestSecretKinds(pushRequest)}
+	if len(check.Kinds) == 0 {
+		check.Allowed = devices
+		return check
+	}
+	for _, device := range devices {
+		switch device.GetSecretGuard() {
+		case SecretGuardAllow:
+			check.Allowed = append(check.Allowed, device)
+		case SecretGuardAllowIfEncrypted:
+			if device.Encryption != nil {
+				check.Allowed = append(check.Allowed, device)
+			} else {
+				check.Blocked = append(check.Blocked, device)
+			}
+		case SecretGuardConfirm:
+			check.Unconfirmed = append(check.Unconfirmed, device)
+		default:
+			check.Blocked = append(check.Blocked, device)
+		}
+	}
+	return check
+}
+
+// pushRequestSecretKinds returns the kinds of the secrets in the text fields of pushRequest.
+func pushRequestSecretKinds(pushRequest *bark.PushRequest) []sensitive.Kind {
+	return sensitive.Kinds(pushRequest.Text())
+}
@@ -0,0 +1,12 @@
+package config
+
+// Hotkeys is the global hotkeys that push the clipboard content,
+// a hotkey is written as modifiers and a key joined by "+", e.g. "Ctrl+Alt+B".
+type Hotkeys struct {
+	// DefaultDevice pushes to the default device.
+	DefaultDevice string `json:"defaultDevice"`
+	// AllDevices pushes to all devices.
+	AllDevices string `json:"allDevices"`
+	// Devices maps device names to the hotkeys that push to them.
+	Devices map[string]string `json:"devices"`
+}
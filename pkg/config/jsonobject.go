@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonObject is a JSON object that keeps the order of its keys and the values
+// it does not know about, it is used to edit the config file without losing
+// the fields that are not defined in Config, e.g. those added by a newer version.
+type jsonObject struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func newJsonObject() *jsonObject {
+	return &jsonObject{values: make(map[string]json.RawMessage)}
+}
+
+func (o *jsonObject) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected a JSON object")
+	}
+	o.keys = nil
+	o.values = make(map[string]json.RawMessage)
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return err
+		}
+		if _, ok := o.values[key]; !ok {
+			o.keys = append(o.keys, key)
+		}
+		o.values[key] = value
+	}
+	_, err = decoder.Token()
+	return err
+}
+
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		keyBytes, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(keyBytes)
+		buffer.WriteByte(':')
+		buffer.Write(o.values[key])
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// Has reports whether the object has key.
+func (o *jsonObject) Has(key string) bool {
+	_, ok := o.values[key]
+	return ok
+}
+
+// Get decodes the value of key into value, it does nothing if key does not exist.
+func (o *jsonObject) Get(key string, value interface{}) error {
+	raw, ok := o.values[key]
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(raw, value)
+}
+
+// Set sets the value of key, a new key is appended to the end.
+func (o *jsonObject) Set(key string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = raw
+	return nil
+}
+
+// Rename renames oldKey to newKey in place, replacing newKey if it exists,
+// it does nothing if oldKey does not exist.
+func (o *jsonObject) Rename(oldKey string, newKey string) {
+	value, ok := o.values[oldKey]
+	if !ok || oldKey == newKey {
+		return
+	}
+	o.Delete(newKey)
+	delete(o.values, oldKey)
+	o.values[newKey] = value
+	for i, k := range o.keys {
+		if k == oldKey {
+			o.keys[i] = newKey
+			break
+		}
+	}
+}
+
+// Delete removes key from the object.
+func (o *jsonObject) Delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
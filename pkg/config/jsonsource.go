@@ -0,0 +1,170 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// sourceLines maps the path of each value in the config file, e.g. "devices[0].key", to its line number.
+type sourceLines map[string]int
+
+// line returns the line number of the value at path, or of its closest parent
+// if the value does not exist, 0 is returned if neither is found.
+func (l sourceLines) line(path string) int {
+	for path != "" {
+		if line, ok := l[path]; ok {
+			return line
+		}
+		index := strings.LastIndexAny(path, ".[")
+		if index < 0 {
+			return 0
+		}
+		path = path[:index]
+	}
+	return 0
+}
+
+// jsonSource is the positions of the values in a JSON document and
+// the keys in it that are not defined by the Go type it is decoded into.
+type jsonSource struct {
+	data    []byte
+	decoder *json.Decoder
+	lines   sourceLines
+	// unknownKeys is the JSON paths of the keys that are not defined by the Go type.
+	unknownKeys []string
+}
+
+// parseJsonSource parses data, whose Go type is t.
+func parseJsonSource(data []byte, t reflect.Type) (*jsonSource, error) {
+	s := &jsonSource{
+		data:    data,
+		decoder: json.NewDecoder(bytes.NewReader(data)),
+		lines:   make(sourceLines),
+	}
+	if err := s.walk("", t); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// nextLine returns the line number of the next token.
+func (s *jsonSource) nextLine() int {
+	offset := int(s.decoder.InputOffset())
+	for offset < len(s.data) && strings.IndexByte(" \t\r\n,:", s.data[offset]) >= 0 {
+		offset++
+	}
+	return bytes.Count(s.data[:offset], []byte("\n")) + 1
+}
+
+func (s *jsonSource) walk(path string, t reflect.Type) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := s.lines[path]; !ok {
+		s.lines[path] = s.nextLine()
+	}
+	token, err := s.decoder.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return nil
+	}
+	switch delim {
+	case '{':
+		var fields map[string]reflect.Type
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = jsonFields(t)
+		}
+		for s.decoder.More() {
+			keyLine := s.nextLine()
+			token, err = s.decoder.Token()
+			if err != nil {
+				return err
+			}
+			key := token.(string)
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			s.lines[keyPath] = keyLine
+			var valueType reflect.Type
+			switch {
+			case fields != nil:
+				valueType, ok = lookupJsonField(fields, key)
+				if !ok {
+					s.unknownKeys = append(s.unknownKeys, keyPath)
+				}
+			case t != nil && t.Kind() == reflect.Map:
+				valueType = t.Elem()
+			}
+			if err = s.walk(keyPath, valueType); err != nil {
+				return err
+			}
+		}
+	case '[':
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+		for i := 0; s.decoder.More(); i++ {
+			if err = s.walk(fmt.Sprintf("%s[%d]", path, i), elemType); err != nil {
+				return err
+			}
+		}
+	}
+	// The closing delimiter
+	_, err = s.decoder.Token()
+	return err
+}
+
+// jsonFields returns the JSON keys of the fields of struct type t, including
+// those of the embedded structs, mapped to their types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embeddedType := field.Type
+			if embeddedType.Kind() == reflect.Ptr {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct {
+				for embeddedName, embeddedFieldType := range jsonFields(embeddedType) {
+					fields[embeddedName] = embeddedFieldType
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// lookupJsonField finds the field of key case-insensitively, like json.Unmarshal does.
+func lookupJsonField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if fieldType, ok := fields[key]; ok {
+		return fieldType, true
+	}
+	for name, fieldType := range fields {
+		if strings.EqualFold(name, key) {
+			return fieldType, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CurrentVersion is the version of the config file schema supported by this build.
+const CurrentVersion = "1.2.0"
+
+// initialVersion is the version of the config files without version.
+const initialVersion = "1.0.0"
+
+// migration upgrades the config file from the previous version to toVersion.
+type migration struct {
+	toVersion string
+	migrate   func(root *jsonObject) error
+}
+
+// migrations is all the migrations in ascending order of toVersion,
+// the config file is upgraded by each migration newer than its version in turn.
+var migrations = []migration{
+	{
+		// 1.1.0 added maxConcurrentPushes and profiles
+		toVersion: "1.1.0",
+		migrate: func(root *jsonObject) error {
+			if !root.Has("maxConcurrentPushes") {
+				if err := root.Set("maxConcurrentPushes", defaultMaxConcurrentPushes); err != nil {
+					return err
+				}
+			}
+			if !root.Has("profiles") {
+				if err := root.Set("profiles", []*Profile{}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// 1.2.0 added groups, history, secretStore, log, logRedaction, devices[].secretGuard
+		// and localApi.metrics, they are all optional and default to the behavior of 1.1.0,
+		// so there is nothing to change but the version. The versions supporting only 1.1.0
+		// reject the upgraded file, as for any newer version.
+		toVersion: "1.2.0",
+		migrate: func(root *jsonObject) error {
+			return nil
+		},
+	},
+}
+
+// parseVersion parses a version like "1.0.3".
+func parseVersion(version string) ([3]int, error) {
+	var parsedVersion [3]int
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return parsedVersion, fmt.Errorf("invalid config version '%s'", version)
+	}
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return parsedVersion, fmt.Errorf("invalid config version '%s'", version)
+		}
+		parsedVersion[i] = number
+	}
+	return parsedVersion, nil
+}
+
+// compareVersions returns -1, 0 or 1 if a is older than, the same as or newer than b.
+func compareVersions(a [3]int, b [3]int) int {
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// migrateObject upgrades root to CurrentVersion, and returns the version it is upgraded from.
+// An error is returned if root is newer than CurrentVersion.
+func migrateObject(root *jsonObject) (fromVersion string, err error) {
+	fromVersion = initialVersion
+	if err = root.Get("version", &fromVersion); err != nil {
+		return "", fmt.Errorf("invalid config version: %s", err.Error())
+	}
+	version, err := parseVersion(fromVersion)
+	if err != nil {
+		return "", err
+	}
+	currentVersion, _ := parseVersion(CurrentVersion)
+	if compareVersions(version, currentVersion) > 0 {
+		return "", fmt.Errorf("the config file is for Bark Tray %s, which is newer than this version (%s), please upgrade Bark Tray", fromVersion, CurrentVersion)
+	}
+	for _, m := range migrations {
+		toVersion, _ := parseVersion(m.toVersion)
+		if compareVersions(version, toVersion) >= 0 {
+			continue
+		}
+		if err = m.migrate(root); err != nil {
+			return "", fmt.Errorf("failed to migrate the config file to %s: %s", m.toVersion, err.Error())
+		}
+		version = toVersion
+	}
+	return fromVersion, root.Set("version", CurrentVersion)
+}
+
+// migrateConfigData upgrades the config file content data to CurrentVersion,
+// migrated is false and data is returned as is if it is already up to date.
+func migrateConfigData(data []byte) (migratedData []byte, fromVersion string, migrated bool, err error) {
+	root := newJsonObject()
+	if err = json.Unmarshal(data, root); err != nil {
+		return nil, "", false, err
+	}
+	var version string
+	if err = root.Get("version", &version); err == nil && version == CurrentVersion {
+		return data, version, false, nil
+	}
+	fromVersion, err = migrateObject(root)
+	if err != nil {
+		return nil, "", false, err
+	}
+	migratedData, err = json.Marshal(root)
+	if err != nil {
+		return nil, "", false, err
+	}
+	migratedData, err = fromJson(formatJson, migratedData, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	return migratedData, fromVersion, true, nil
+}
+
+// MigrateConfigFile upgrades the config file to CurrentVersion in place if it is older,
+// the original file is backed up next to it, and its path is returned as backupFilePath.
+// backupFilePath is empty if the config file is already up to date.
+func MigrateConfigFile(configFilePath string) (backupFilePath string, err error) {
+	format, err := formatOf(configFilePath)
+	if err != nil {
+		return "", err
+	}
+	fileInfo, err := os.Stat(configFilePath)
+	if err != nil {
+		return "", err
+	}
+	configFileBytes, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := toJson(format, configFileBytes)
+	if err != nil {
+		return "", err
+	}
+	migratedBytes, fromVersion, migrated, err := migrateConfigData(jsonBytes)
+	if err != nil || !migrated {
+		return "", err
+	}
+	backupFilePath = fmt.Sprintf("%s.%s-%s.bak", configFilePath, fromVersion, time.Now().Format("20060102-150405"))
+	if err = os.WriteFile(backupFilePath, configFileBytes, fileInfo.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to back up the config file: %s", err.Error())
+	}
+	err = writeConfigData(configFilePath, format, migratedBytes, configFileBytes, fileInfo.Mode().Perm())
+	if err != nil {
+		return "", err
+	}
+	return backupFilePath, nil
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:  "1.0.3 to 1.2.0",
+			input: `{"version":"1.0.3","timeout":5,"devices":[]}`,
+			expected: `{
+  "version": "1.2.0",
+  "timeout": 5,
+  "devices": [],
+  "maxConcurrentPushes": 4,
+  "profiles": []
+}
+`,
+		},
+		{
+			name:  "missing version is 1.0.0",
+			input: `{"timeout":5,"unknown":{"kept":true}}`,
+			expected: `{
+  "timeout": 5,
+  "unknown": {
+    "kept": true
+  },
+  "maxConcurrentPushes": 4,
+  "profiles": [],
+  "version": "1.2.0"
+}
+`,
+		},
+		{
+			name:  "existing fields are not overridden",
+			input: `{"version":"1.0.0","maxConcurrentPushes":8,"profiles":[{"name":"Search"}]}`,
+			expected: `{
+  "version": "1.2.0",
+  "maxConcurrentPushes": 8,
+  "profiles": [
+    {
+      "name": "Search"
+    }
+  ]
+}
+`,
+		},
+	}
+	for _, testCase := range testCases {
+		migratedData, _, migrated, err := migrateConfigData([]byte(testCase.input))
+		assert.Nil(t, err, testCase.name)
+		assert.True(t, migrated, testCase.name)
+		assert.Equal(t, testCase.expected, string(migratedData), testCase.name)
+	}
+}
+
+func TestMigrateFrom110(t *testing.T) {
+	// A 1.1.0 config file with all the fields added in 1.2.0 is only upgraded to 1.2.0,
+	// and each of them is decoded by LoadConfig
+	input := `{
+  "version": "1.1.0",
+  "timeout": 5,
+  "maxConcurrentPushes": 4,
+  "logRedaction": "hash",
+  "log": {"level": "debug", "encoding": "json", "maxSizeMb": 5},
+  "devices": [
+    {"name": "iPhone", "barkBaseUrl": "https://api.day.app", "key": "key1", "secretGuard": "block"}
+  ],
+  "profiles": [],
+  "groups": [{"name": "All", "devices": ["iPhone"]}],
+  "history": {"mode": "redacted", "maxEntries": 10},
+  "secretStore": {"backend": "file", "file": "secrets.enc"},
+  "localApi": {"enabled": true, "listen": "127.0.0.1:8765", "token": "token", "metrics": true}
+}`
+	migratedData, fromVersion, migrated, err := migrateConfigData([]byte(input))
+	assert.Nil(t, err)
+	assert.True(t, migrated)
+	assert.Equal(t, "1.1.0", fromVersion)
+	expected, err := fromJson(formatJson, []byte(strings.Replace(input, `"1.1.0"`, `"1.2.0"`, 1)), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(migratedData))
+
+	configFilePath := filepath.Join(t.TempDir(), "config.json")
+	assert.Nil(t, os.WriteFile(configFilePath, []byte(input), 0600))
+	config, err := LoadConfig(configFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, CurrentVersion, config.Version)
+	assert.Nil(t, config.Validate())
+	assert.Equal(t, logger.RedactionHash, config.LogRedaction)
+	assert.Equal(t, "debug", config.Log.Level)
+	assert.Equal(t, "json", config.Log.Encoding)
+	assert.Equal(t, 5, config.Log.MaxSizeMb)
+	assert.Equal(t, SecretGuardBlock, config.Devices[0].SecretGuard)
+	assert.Equal(t, []*Group{{Name: "All", Devices: []string{"iPhone"}}}, config.Groups)
+	assert.Equal(t, "redacted", config.History.GetMode())
+	assert.Equal(t, 10, config.History.GetMaxEntries())
+	assert.Equal(t, "file", config.SecretStore.Backend)
+	assert.Equal(t, "secrets.enc", config.SecretStore.File)
+	assert.True(t, config.LocalApi.Metrics)
+}
+
+func TestMigrateConfigData(t *testing.T) {
+	data := []byte(`{"version": "` + CurrentVersion + `"}`)
+	migratedData, fromVersion, migrated, err := migrateConfigData(data)
+	assert.Nil(t, err)
+	assert.False(t, migrated)
+	assert.Equal(t, CurrentVersion, fromVersion)
+	assert.Equal(t, data, migratedData)
+
+	for _, version := range []string{"1.2.1", "2.0.0"} {
+		_, _, _, err = migrateConfigData([]byte(`{"version": "` + version + `"}`))
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "newer")
+	}
+	for _, version := range []string{`"1.0"`, `"latest"`, `1`} {
+		_, _, _, err = migrateConfigData([]byte(`{"version": ` + version + `}`))
+		assert.NotNil(t, err, version)
+	}
+}
+
+func TestMigrateConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	configFilePath := filepath.Join(dir, "config.json")
+	original := []byte(`{"version": "1.0.3", "timeout": 5, "devices": []}`)
+	assert.Nil(t, os.WriteFile(configFilePath, original, 0600))
+
+	backupFilePath, err := MigrateConfigFile(configFilePath)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(filepath.Base(backupFilePath), "config.json.1.0.3-"))
+	backup, err := os.ReadFile(backupFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, original, backup)
+
+	config, err := LoadConfig(configFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, CurrentVersion, config.Version)
+	assert.Equal(t, 4, config.MaxConcurrentPushes)
+
+	backupFilePath, err = MigrateConfigFile(configFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "", backupFilePath)
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	// ConfigFileEnv is the environment variable of the config file path.
+	ConfigFileEnv = "BARK_TRAY_CONFIG"
+	// ConfigFileName is the name of the config file created in the user config directory.
+	ConfigFileName = "config.json"
+	// appDirName is the name of the directory of Bark Tray in the user directories.
+	appDirName = "bark-tray"
+)
+
+// configFileNames is the names of the config file looked up in the config directories in order.
+var configFileNames = []string{ConfigFileName, "config.yaml", "config.yml", "config.toml"}
+
+// Paths is where the config file and the data of Bark Tray, i.e. the log file and the outbox, are.
+type Paths struct {
+	// ConfigFilePath is the absolute path of the config file, which may not exist yet.
+	ConfigFilePath string
+	// DataDir is the directory of the outbox, and the directory the relative log file path is relative to.
+	DataDir string
+	// Portable is true if the config file is in the directory of the executable,
+	// in which case the data are kept in the same directory.
+	Portable bool
+}
+
+// ResolvePaths finds the config file, the first of the following is used:
+//
+//  1. configFlag, the value of the --config flag, if it is not empty
+//  2. the BARK_TRAY_CONFIG environment variable, if it is set
+//  3. config.json, config.yaml, config.yml or config.toml in the bark-tray directory
+//     of the user config directory, if it exists
+//  4. config.json, config.yaml, config.yml or config.toml in executableDir, if it exists
+//
+// If none of them exists, config.json is expected in the user config directory.
+// The data are kept in the user state directory, unless the config file is in executableDir.
+func ResolvePaths(configFlag string, executableDir string) (*Paths, error) {
+	configFilePath := configFlag
+	if configFilePath == "" {
+		configFilePath = os.Getenv(ConfigFileEnv)
+	}
+	if configFilePath == "" {
+		userConfigDir, userConfigDirErr := os.UserConfigDir()
+		if userConfigDirErr == nil {
+			userConfigDir = filepath.Join(userConfigDir, appDirName)
+		}
+		userConfigFilePath := findConfigFile(userConfigDir)
+		portableConfigFilePath := findConfigFile(executableDir)
+		switch {
+		case userConfigDirErr == nil && userConfigFilePath != "":
+			configFilePath = userConfigFilePath
+		case executableDir != "" && portableConfigFilePath != "":
+			configFilePath = portableConfigFilePath
+		case userConfigDirErr == nil:
+			configFilePath = filepath.Join(userConfigDir, ConfigFileName)
+		default:
+			return nil, fmt.Errorf("failed to get the user config directory: %s", userConfigDirErr.Error())
+		}
+	}
+	configFilePath, err := filepath.Abs(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := &Paths{ConfigFilePath: configFilePath}
+	if executableDir != "" && filepath.Dir(configFilePath) == filepath.Clean(executableDir) {
+		paths.Portable = true
+		paths.DataDir = executableDir
+		return paths, nil
+	}
+	paths.DataDir, err = userStateDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the user state directory: %s", err.Error())
+	}
+	return paths, nil
+}
+
+// findConfigFile returns the path of the first config file that exists in dir, or empty if none exists.
+func findConfigFile(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	for _, fileName := range configFileNames {
+		configFilePath := filepath.Join(dir, fileName)
+		if util.IsFileExists(configFilePath) {
+			return configFilePath
+		}
+	}
+	return ""
+}
+
+// userStateDir returns the directory to keep the data that should persist between restarts but are
+// not worth backing up, i.e. $XDG_STATE_HOME/bark-tray or ~/.local/state/bark-tray on Unix,
+// %LocalAppData%\bark-tray on Windows and ~/Library/Application Support/bark-tray on macOS.
+func userStateDir() (string, error) {
+	var dir string
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		dir, err = os.UserCacheDir()
+	case "darwin", "ios", "plan9":
+		dir, err = os.UserConfigDir()
+	default:
+		dir = os.Getenv("XDG_STATE_HOME")
+		if dir == "" {
+			var homeDir string
+			homeDir, err = os.UserHomeDir()
+			dir = filepath.Join(homeDir, ".local", "state")
+		} else if !filepath.IsAbs(dir) {
+			err = errors.New("path in $XDG_STATE_HOME is relative")
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDirName), nil
+}
@@ -0,0 +1,68 @@
+//go:build linux
+
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePaths(t *testing.T) {
+	homeDir := t.TempDir()
+	executableDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv(ConfigFileEnv, "")
+	userConfigFilePath := filepath.Join(homeDir, ".config", "bark-tray", "config.json")
+	stateDir := filepath.Join(homeDir, ".local", "state", "bark-tray")
+	portableConfigFilePath := filepath.Join(executableDir, "config.json")
+
+	// No config file, expected in the user config directory
+	paths, err := ResolvePaths("", executableDir)
+	assert.Nil(t, err)
+	assert.Equal(t, &Paths{ConfigFilePath: userConfigFilePath, DataDir: stateDir}, paths)
+
+	// The config file in the executable directory
+	assert.Nil(t, os.WriteFile(portableConfigFilePath, []byte("{}"), 0644))
+	paths, err = ResolvePaths("", executableDir)
+	assert.Nil(t, err)
+	assert.Equal(t, &Paths{ConfigFilePath: portableConfigFilePath, DataDir: executableDir, Portable: true}, paths)
+
+	// The config file in the user config directory takes precedence
+	userYamlConfigFilePath := filepath.Join(homeDir, ".config", "bark-tray", "config.yaml")
+	assert.Nil(t, CreateConfigFileTemplate(userYamlConfigFilePath))
+	paths, err = ResolvePaths("", executableDir)
+	assert.Nil(t, err)
+	assert.Equal(t, &Paths{ConfigFilePath: userYamlConfigFilePath, DataDir: stateDir}, paths)
+
+	// config.json takes precedence over the other formats
+	assert.Nil(t, CreateConfigFileTemplate(userConfigFilePath))
+	paths, err = ResolvePaths("", executableDir)
+	assert.Nil(t, err)
+	assert.Equal(t, &Paths{ConfigFilePath: userConfigFilePath, DataDir: stateDir}, paths)
+
+	// The environment variable takes precedence
+	envConfigFilePath := filepath.Join(t.TempDir(), "env.json")
+	t.Setenv(ConfigFileEnv, envConfigFilePath)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(homeDir, "state"))
+	paths, err = ResolvePaths("", executableDir)
+	assert.Nil(t, err)
+	assert.Equal(t, &Paths{ConfigFilePath: envConfigFilePath, DataDir: filepath.Join(homeDir, "state", "bark-tray")}, paths)
+
+	// The flag takes precedence, and the relative path is relative to the working directory
+	workingDir, err := os.Getwd()
+	assert.Nil(t, err)
+	paths, err = ResolvePaths("flag.json", executableDir)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(workingDir, "flag.json"), paths.ConfigFilePath)
+	assert.False(t, paths.Portable)
+
+	// The flag pointing to the executable directory is portable
+	paths, err = ResolvePaths(portableConfigFilePath, executableDir)
+	assert.Nil(t, err)
+	assert.True(t, paths.Portable)
+	assert.Equal(t, executableDir, paths.DataDir)
+}
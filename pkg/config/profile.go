@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// ProfileUrlAuto uses the first url in the message as the url of the notification.
+	ProfileUrlAuto = "auto"
+	// ProfileUrlNone sends the notification without url.
+	ProfileUrlNone = "none"
+)
+
+// Profile is a named set of push settings, such as the title and the sound,
+// for a kind of clipboard content.
+type Profile struct {
+	Name string `json:"name"`
+	// Title is a text/template rendered with ProfileTemplateData, e.g. "From {{.Hostname}}".
+	Title string `json:"title"`
+	// AutoCopy makes the Bark App copy the message automatically when the notification is received.
+	AutoCopy bool `json:"autoCopy"`
+	// Url is ProfileUrlAuto, ProfileUrlNone, or a text/template rendered with
+	// ProfileTemplateData, e.g. "https://www.google.com/search?q={{urlquery .Text}}".
+	// Defaults to ProfileUrlAuto.
+	Url string `json:"url"`
+	bark.PushOptions
+
+	titleTemplate *template.Template
+	urlTemplate   *template.Template
+}
+
+// ProfileTemplateData is the data used to render the templates of Profile.
+type ProfileTemplateData struct {
+	// Text is the message.
+	Text string
+	// FirstLine is the first line of the message.
+	FirstLine string
+	Hostname  string
+	// Timestamp is the current time in "2006-01-02 15:04:05" format.
+	Timestamp string
+	// Time is the current time, which can be formatted as needed, e.g. {{.Time.Format "15:04"}}.
+	Time time.Time
+}
+
+func newProfileTemplateData(text string) *ProfileTemplateData {
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	return &ProfileTemplateData{
+		Text:      text,
+		FirstLine: strings.TrimSpace(strings.SplitN(text, "\n", 2)[0]),
+		Hostname:  hostname,
+		Timestamp: now.Format("2006-01-02 15:04:05"),
+		Time:      now,
+	}
+}
+
+// Init validates the profile and parses its templates, it must be called before Apply.
+func (p *Profile) Init() error {
+	if p.Name == "" {
+		return fmt.Errorf("profile name is empty")
+	}
+	if err := p.PushOptions.Validate(); err != nil {
+		return err
+	}
+	var err error
+	if p.Title != "" {
+		p.titleTemplate, err = template.New("title").Parse(p.Title)
+		if err != nil {
+			return fmt.Errorf("invalid title template: %s", err.Error())
+		}
+	}
+	switch p.Url {
+	case "", ProfileUrlAuto, ProfileUrlNone:
+	default:
+		p.urlTemplate, err = template.New("url").Parse(p.Url)
+		if err != nil {
+			return fmt.Errorf("invalid url template: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// Apply applies the profile to pushRequest, the templates are rendered
+// with pushRequest.Body as the message.
+func (p *Profile) Apply(pushRequest *bark.PushRequest) error {
+	data := newProfileTemplateData(pushRequest.Body)
+	if p.titleTemplate != nil {
+		title, err := renderTemplate(p.titleTemplate, data)
+		if err != nil {
+			return err
+		}
+		pushRequest.Title = title
+	}
+	switch p.Url {
+	case "", ProfileUrlAuto:
+	case ProfileUrlNone:
+		pushRequest.Url = ""
+	default:
+		messageUrl, err := renderTemplate(p.urlTemplate, data)
+		if err != nil {
+			return err
+		}
+		if !util.IsValidHttpUrl(messageUrl) {
+			return fmt.Errorf("the url rendered by profile '%s' is not a valid url: %s", p.Name, messageUrl)
+		}
+		pushRequest.Url = messageUrl
+	}
+	if p.AutoCopy {
+		pushRequest.AutomaticallyCopy = "1"
+		pushRequest.Copy = pushRequest.Body
+	}
+	p.PushOptions.Apply(pushRequest)
+	return nil
+}
+
+func renderTemplate(t *template.Template, data *ProfileTemplateData) (string, error) {
+	var builder strings.Builder
+	if err := t.Execute(&builder, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(builder.String()), nil
+}
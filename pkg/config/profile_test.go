@@ -0,0 +1,55 @@
+package config
+
+import (
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestProfileApply(t *testing.T) {
+	profile := &Profile{
+		Name:     "Code",
+		Title:    "{{.FirstLine}} from {{.Hostname}}",
+		AutoCopy: true,
+		Url:      ProfileUrlNone,
+		PushOptions: bark.PushOptions{
+			Group: "codes",
+			Sound: bark.PushSoundBell,
+			Level: bark.PushLevelTimeSensitive,
+		},
+	}
+	assert.Nil(t, profile.Init())
+	pushRequest := NewTextPushRequest("123456\nhttps://example.org")
+	assert.Nil(t, profile.Apply(pushRequest))
+	hostname, _ := os.Hostname()
+	assert.Equal(t, "123456 from "+hostname, pushRequest.Title)
+	assert.Equal(t, "", pushRequest.Url)
+	assert.Equal(t, "1", pushRequest.AutomaticallyCopy)
+	assert.Equal(t, "123456\nhttps://example.org", pushRequest.Copy)
+	assert.Equal(t, "codes", pushRequest.Group)
+	assert.Equal(t, "bell.caf", pushRequest.Sound)
+	assert.Equal(t, bark.PushLevelTimeSensitive, pushRequest.Level)
+}
+
+func TestProfileUrlTemplate(t *testing.T) {
+	profile := &Profile{Name: "Search", Url: "https://www.google.com/search?q={{urlquery .Text}}"}
+	assert.Nil(t, profile.Init())
+	pushRequest := NewTextPushRequest("bark tray")
+	assert.Nil(t, profile.Apply(pushRequest))
+	assert.Equal(t, "https://www.google.com/search?q=bark+tray", pushRequest.Url)
+	assert.Equal(t, "", pushRequest.Title)
+
+	autoProfile := &Profile{Name: "Link"}
+	assert.Nil(t, autoProfile.Init())
+	pushRequest = NewTextPushRequest("see https://example.org")
+	assert.Nil(t, autoProfile.Apply(pushRequest))
+	assert.Equal(t, "https://example.org", pushRequest.Url)
+}
+
+func TestProfileInit(t *testing.T) {
+	assert.NotNil(t, (&Profile{}).Init())
+	assert.NotNil(t, (&Profile{Name: "Broken", Title: "{{.Text"}).Init())
+	assert.NotNil(t, (&Profile{Name: "Broken", Url: "{{"}).Init())
+	assert.NotNil(t, (&Profile{Name: "Loud", PushOptions: bark.PushOptions{Level: "loud"}}).Init())
+}
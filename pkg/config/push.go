@@ -0,0 +1,128 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/dispatcher"
+	"go.uber.org/zap"
+	"time"
+)
+
+// PushResult is the result of pushing a message to a device.
+type PushResult struct {
+	Device *Device
+	// Response is the response of the Bark server, it is nil if Err is not nil.
+	Response *bark.PushResponse
+	Err      error
+	Duration time.Duration
+}
+
+// PushResults is the results of pushing a message to multiple devices.
+type PushResults []*PushResult
+
+// Failures returns the results that failed.
+func (r PushResults) Failures() PushResults {
+	var failures PushResults
+	for _, result := range r {
+		if result.Err != nil {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// LogFields returns the log fields of the result, i.e. the device, the duration,
+// the status code of the response and the error.
+func (r *PushResult) LogFields() []zap.Field {
+	fields := append(r.Device.LogFields(), zap.Duration("duration", r.Duration))
+	var pushError *bark.PushError
+	switch {
+	case r.Response != nil:
+		fields = append(fields, zap.Int("status", r.Response.Code))
+	case errors.As(r.Err, &pushError):
+		fields = append(fields, zap.Int("status", pushError.Code))
+	}
+	if r.Err != nil {
+		fields = append(fields, zap.Error(r.Err))
+	}
+	return fields
+}
+
+// Summary returns a human-readable summary of the results,
+// e.g. "3/4 delivered, MY_IPAD failed: timeout".
+func (r PushResults) Summary() string {
+	failures := r.Failures()
+	summary := fmt.Sprintf("%d/%d delivered", len(r)-len(failures), len(r))
+	for _, failure := range failures {
+		summary += fmt.Sprintf(", %s failed: %s", failure.Device.Name, failure.Err.Error())
+	}
+	return summary
+}
+
+// PushToDevices pushes pushRequest to all devices concurrently with at most
+// maxWorkers requests at the same time, and returns the result of each device
+// in the same order as devices.
+// Devices sharing the same BarkBaseUrl and PushOptions are pushed with a single batch request,
+// if the server does not support batch push, each of them is pushed individually,
+// while any other failure of the batch request fails all of them.
+// Devices with Encryption are always pushed individually since each of them has its own key.
+func PushToDevices(devices []*Device, pushRequest *bark.PushRequest, maxWorkers int) PushResults {
+	results := make(PushResults, len(devices))
+	d := dispatcher.New(maxWorkers)
+
+	pushToDevice := func(i int) {
+		d.Go(func() {
+			startTime := time.Now()
+			pushResponse, err := devices[i].PushWithResponse(pushRequest)
+			results[i] = &PushResult{Device: devices[i], Response: pushResponse, Err: err, Duration: time.Since(startTime)}
+		})
+	}
+
+	batches := make(map[string][]int)
+	var batchKeys []string
+	for i, device := range devices {
+		if device.Encryption != nil {
+			pushToDevice(i)
+			continue
+		}
+		batchKey := device.BarkBaseUrl
+		if device.PushOptions != nil {
+			pushOptionsBytes, _ := json.Marshal(device.PushOptions)
+			batchKey += string(pushOptionsBytes)
+		}
+		if _, ok := batches[batchKey]; !ok {
+			batchKeys = append(batchKeys, batchKey)
+		}
+		batches[batchKey] = append(batches[batchKey], i)
+	}
+
+	batchResults := make(map[string][]*bark.PushResult)
+	for _, batchKey := range batchKeys {
+		deviceIndexes := batches[batchKey]
+		if len(deviceIndexes) == 1 {
+			pushToDevice(deviceIndexes[0])
+			continue
+		}
+		deviceKeys := make([]string, len(deviceIndexes))
+		for i, deviceIndex := range deviceIndexes {
+			deviceKeys[i] = devices[deviceIndex].Key
+		}
+		// All devices in the batch share the same BarkBaseUrl and PushOptions
+		firstDevice := devices[deviceIndexes[0]]
+		batchResults[batchKey] = bark.DispatchBatchPush(d, firstDevice.BarkBaseUrl, firstDevice.applyPushOptions(pushRequest), deviceKeys)
+	}
+
+	d.Wait()
+	for batchKey, batchResult := range batchResults {
+		for i, deviceIndex := range batches[batchKey] {
+			result := &PushResult{Device: devices[deviceIndex], Duration: batchResult[i].Duration}
+			if result.Err = checkPushResponse(batchResult[i].Response, batchResult[i].Err); result.Err == nil {
+				result.Response = batchResult[i].Response
+			}
+			results[deviceIndex] = result
+		}
+	}
+	return results
+}
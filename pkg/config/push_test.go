@@ -0,0 +1,145 @@
+package config
+
+import (
+	"encoding/json"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/httpClient"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestBarkServer(t *testing.T, supportBatch bool) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var deviceKeys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pushRequest bark.PushRequest
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&pushRequest))
+		mu.Lock()
+		defer mu.Unlock()
+		if pushRequest.DeviceKey == "" && !supportBatch {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":400,"message":"device key is empty","timestamp":1}`))
+			return
+		}
+		if pushRequest.DeviceKey == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":400,"message":"failed to get device token","timestamp":1}`))
+			return
+		}
+		if pushRequest.DeviceKey != "" {
+			deviceKeys = append(deviceKeys, pushRequest.DeviceKey)
+		}
+		deviceKeys = append(deviceKeys, pushRequest.DeviceKeys...)
+		_, _ = w.Write([]byte(`{"code":200,"message":"success","timestamp":1}`))
+	}))
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return deviceKeys
+	}
+}
+
+func TestPushToDevicesBatch(t *testing.T) {
+	server, receivedKeys := newTestBarkServer(t, true)
+	defer server.Close()
+	httpClient.Setup("Bark Tray Test", 5)
+
+	devices := []*Device{
+		{Name: "A", BarkBaseUrl: server.URL, Key: "a"},
+		{Name: "B", BarkBaseUrl: server.URL, Key: "b"},
+	}
+	results := PushToDevices(devices, NewTextPushRequest("hello"), 2)
+	assert.Equal(t, []string{"a", "b"}, receivedKeys())
+	assert.Len(t, results.Failures(), 0)
+	assert.Equal(t, "2/2 delivered", results.Summary())
+}
+
+func TestPushToDevicesFallback(t *testing.T) {
+	server, receivedKeys := newTestBarkServer(t, false)
+	defer server.Close()
+	httpClient.Setup("Bark Tray Test", 5)
+
+	devices := []*Device{
+		{Name: "A", BarkBaseUrl: server.URL, Key: "a"},
+		{Name: "BAD", BarkBaseUrl: server.URL, Key: "bad"},
+		{Name: "C", BarkBaseUrl: server.URL, Key: "c"},
+		{Name: "D", BarkBaseUrl: server.URL, Key: "d"},
+	}
+	results := PushToDevices(devices, NewTextPushRequest("hello"), 2)
+	assert.ElementsMatch(t, []string{"a", "c", "d"}, receivedKeys())
+	for i, device := range devices {
+		assert.Equal(t, device, results[i].Device)
+	}
+	assert.Len(t, results.Failures(), 1)
+	assert.Equal(t, "3/4 delivered, BAD failed: failed to get device token", results.Summary())
+}
+
+func TestPushToDevicesBatchServerError(t *testing.T) {
+	var mu sync.Mutex
+	requestCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requestCount++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"code":503,"message":"service unavailable","timestamp":1}`))
+	}))
+	defer server.Close()
+	httpClient.Setup("Bark Tray Test", 5)
+
+	devices := []*Device{
+		{Name: "A", BarkBaseUrl: server.URL, Key: "a"},
+		{Name: "B", BarkBaseUrl: server.URL, Key: "b"},
+	}
+	results := PushToDevices(devices, NewTextPushRequest("hello"), 2)
+	// The devices are not pushed individually, which could deliver the message twice
+	assert.Equal(t, 1, requestCount)
+	assert.Len(t, results.Failures(), 2)
+	assert.Equal(t, "0/2 delivered, A failed: service unavailable, B failed: service unavailable", results.Summary())
+}
+
+func TestPushToDevicesFallbackMaxWorkers(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pushRequest bark.PushRequest
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&pushRequest))
+		if pushRequest.DeviceKey == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":400,"message":"device key is empty","timestamp":1}`))
+			return
+		}
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"code":200,"message":"success","timestamp":1}`))
+	})
+	server1 := httptest.NewServer(handler)
+	defer server1.Close()
+	server2 := httptest.NewServer(handler)
+	defer server2.Close()
+	httpClient.Setup("Bark Tray Test", 5)
+
+	var devices []*Device
+	for _, key := range []string{"a", "b", "c"} {
+		devices = append(devices, &Device{Name: key, BarkBaseUrl: server1.URL, Key: key})
+	}
+	for _, key := range []string{"d", "e", "f"} {
+		devices = append(devices, &Device{Name: key, BarkBaseUrl: server2.URL, Key: key})
+	}
+	results := PushToDevices(devices, NewTextPushRequest("hello"), 2)
+	assert.Equal(t, "6/6 delivered", results.Summary())
+	// The fallback pushes of both batches share the limit with all the other pushes
+	assert.LessOrEqual(t, maxInFlight, 2)
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tomlToNode parses the TOML data into a node, keeping the order of the keys.
+func tomlToNode(data []byte) (*yaml.Node, error) {
+	var root map[string]interface{}
+	metaData, err := toml.Decode(string(data), &root)
+	if err != nil {
+		return nil, err
+	}
+	// The keys of each table in the order they appear, the items of an
+	// array of tables share the same path as they are not indexed.
+	keyOrders := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, key := range metaData.Keys() {
+		path := strings.Join(key, "\x00")
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		parentPath := strings.Join(key[:len(key)-1], "\x00")
+		keyOrders[parentPath] = append(keyOrders[parentPath], key[len(key)-1])
+	}
+	return tomlValueToNode(root, "", keyOrders)
+}
+
+func tomlValueToNode(value interface{}, path string, keyOrders map[string][]string) (*yaml.Node, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range orderedKeys(value, keyOrders[path]) {
+			child, err := tomlValueToNode(value[key], joinTomlPath(path, key), keyOrders)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, scalarNode("!!str", key), child)
+		}
+		return node, nil
+	case []map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range value {
+			child, err := tomlValueToNode(item, path, keyOrders)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range value {
+			child, err := tomlValueToNode(item, path, keyOrders)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	case string:
+		return scalarNode("!!str", value), nil
+	case int64:
+		return scalarNode("!!int", strconv.FormatInt(value, 10)), nil
+	case float64:
+		return scalarNode("!!float", strconv.FormatFloat(value, 'g', -1, 64)), nil
+	case bool:
+		return scalarNode("!!bool", strconv.FormatBool(value)), nil
+	case time.Time:
+		return scalarNode("!!timestamp", value.Format(time.RFC3339Nano)), nil
+	case encoding.TextMarshaler:
+		// The local date and time
+		text, err := value.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return scalarNode("!!str", string(text)), nil
+	}
+	return nil, fmt.Errorf("unsupported TOML value %v", value)
+}
+
+func joinTomlPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "\x00" + key
+}
+
+// orderedKeys returns the keys of table in keyOrder, followed by the sorted keys not in keyOrder.
+func orderedKeys(table map[string]interface{}, keyOrder []string) []string {
+	keys := make([]string, 0, len(table))
+	added := make(map[string]bool)
+	for _, key := range keyOrder {
+		if _, ok := table[key]; ok && !added[key] {
+			keys = append(keys, key)
+			added[key] = true
+		}
+	}
+	var remainingKeys []string
+	for key := range table {
+		if !added[key] {
+			remainingKeys = append(remainingKeys, key)
+		}
+	}
+	sort.Strings(remainingKeys)
+	return append(keys, remainingKeys...)
+}
+
+// nodeToToml encodes the mapping node as a TOML document, keeping the order of the keys
+// except that the tables must come after the other values. The null values are dropped.
+func nodeToToml(node *yaml.Node) ([]byte, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, errors.New("the root of the config file must be a table")
+	}
+	value, err := nodeToTomlValue(node)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	encoder := toml.NewEncoder(&buffer)
+	encoder.Indent = ""
+	if err = encoder.Encode(value.Interface()); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// nodeToTomlValue converts node to a value encoded by toml.Encoder, a mapping is
+// converted to a struct, whose fields are in the order of the keys.
+func nodeToTomlValue(node *yaml.Node) (reflect.Value, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return nodeToTomlValue(node.Alias)
+	case yaml.MappingNode:
+		var fields []reflect.StructField
+		var values []reflect.Value
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if key == "-" || strings.Contains(key, ",") {
+				return reflect.Value{}, fmt.Errorf("key '%s' cannot be converted to TOML", key)
+			}
+			if node.Content[i+1].Tag == "!!null" {
+				continue
+			}
+			value, err := nodeToTomlValue(node.Content[i+1])
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			fields = append(fields, reflect.StructField{
+				Name: fmt.Sprintf("F%d", len(fields)),
+				Type: value.Type(),
+				Tag:  reflect.StructTag(fmt.Sprintf("toml:%q", key)),
+			})
+			values = append(values, value)
+		}
+		table := reflect.New(reflect.StructOf(fields)).Elem()
+		for i, value := range values {
+			table.Field(i).Set(value)
+		}
+		return table, nil
+	case yaml.SequenceNode:
+		array := make([]interface{}, 0, len(node.Content))
+		for _, child := range node.Content {
+			if child.Tag == "!!null" {
+				return reflect.Value{}, fmt.Errorf("line %d: null cannot be converted to TOML", child.Line)
+			}
+			value, err := nodeToTomlValue(child)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			array = append(array, value.Interface())
+		}
+		return reflect.ValueOf(array), nil
+	case yaml.ScalarNode:
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(value), nil
+	}
+	return reflect.Value{}, fmt.Errorf("line %d: unsupported YAML node", node.Line)
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/clipwatcher"
+	"github.com/LGiki/bark-tray/pkg/history"
+	"github.com/LGiki/bark-tray/pkg/hotkeys"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	minTimeout = 1
+	maxTimeout = 300
+)
+
+// ValidationError is a problem found in the config file.
+type ValidationError struct {
+	// Path is the JSON path of the invalid value, e.g. "devices[0].key".
+	Path string
+	// Line is the line number of the invalid value in the config file, 0 if it is unknown.
+	Line    int
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s (line %d): %s", e.Path, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors is all the problems found in the config file.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+type validator struct {
+	lines  sourceLines
+	errors ValidationErrors
+}
+
+func (v *validator) add(path string, format string, args ...interface{}) {
+	v.errors = append(v.errors, &ValidationError{
+		Path:    path,
+		Line:    v.lines.line(path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Validate checks every field of the config, and returns ValidationErrors with all
+// the problems found, or nil if there is none. The line numbers and the unknown keys
+// are only reported for the config loaded by LoadConfig.
+// LogFilePath must have been converted to an absolute path.
+func (c *Config) Validate() error {
+	v := &validator{}
+	if c.source != nil {
+		// The line numbers are those in the config file, while the unknown keys
+		// are found in the migrated config, as the migrations may rename keys.
+		v.lines = sourceLinesOf(c.sourceFormat, c.source)
+		if migratedSource, err := parseJsonSource(c.migratedSource, configType); err == nil {
+			for _, path := range migratedSource.unknownKeys {
+				v.add(path, "unknown field")
+			}
+		}
+	}
+
+	if c.Timeout < minTimeout || c.Timeout > maxTimeout {
+		v.add("timeout", "must be between %d and %d seconds, got %d", minTimeout, maxTimeout, c.Timeout)
+	}
+	if c.MaxConcurrentPushes < 0 {
+		v.add("maxConcurrentPushes", "must not be negative, got %d", c.MaxConcurrentPushes)
+	}
+	if c.EnableLog {
+		if err := checkFileWritable(c.LogFilePath); err != nil {
+			v.add("logFilePath", "log file is not writable: %s", err.Error())
+		}
+	}
+	if c.Log != nil {
+		if err := c.Log.Validate(); err != nil {
+			v.add("log", "%s", err.Error())
+		}
+	}
+	if c.LogRedaction != "" && !c.LogRedaction.IsValid() {
+		v.add("logRedaction", "unsupported log redaction '%s', must be one of none, length, hash and truncate", c.LogRedaction)
+	}
+	c.validateDevices(v)
+	c.validateProfiles(v)
+	c.validateGroups(v)
+	if c.ImageHost != nil {
+		if err := c.ImageHost.Validate(); err != nil {
+			v.add("imageHost", "%s", err.Error())
+		}
+	}
+	if c.WatchClipboard != nil {
+		if _, err := clipwatcher.NewFilter(c.WatchClipboard); err != nil {
+			v.add("watchClipboard", "%s", err.Error())
+		}
+	}
+	c.validateHotkeys(v)
+	if c.LocalApi != nil && c.LocalApi.Enabled {
+		if err := c.LocalApi.Validate(); err != nil {
+			v.add("localApi", "%s", err.Error())
+		}
+	}
+	if c.History != nil {
+		if _, err := history.NewPolicy(c.History); err != nil {
+			v.add("history", "%s", err.Error())
+		}
+	}
+	if c.SecretStore != nil {
+		if err := c.SecretStore.Validate(); err != nil {
+			v.add("secretStore", "%s", err.Error())
+		}
+	}
+
+	if len(v.errors) == 0 {
+		return nil
+	}
+	// Report the problems in the order they appear in the config file
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		return v.errors[i].Line > 0 && (v.errors[j].Line == 0 || v.errors[i].Line < v.errors[j].Line)
+	})
+	return v.errors
+}
+
+func (c *Config) validateDevices(v *validator) {
+	deviceNames := make(map[string]int)
+	deviceKeys := make(map[string]int)
+	defaultDeviceIndex := -1
+	for i, device := range c.Devices {
+		path := fmt.Sprintf("devices[%d]", i)
+		if device.Name == "" {
+			v.add(path+".name", "device name is empty")
+		} else if index, ok := deviceNames[device.Name]; ok {
+			v.add(path+".name", "duplicate device name '%s', it is also used by devices[%d]", device.Name, index)
+		} else {
+			deviceNames[device.Name] = i
+		}
+		if device.Key == "" {
+			v.add(path+".key", "device key is empty")
+		} else if index, ok := deviceKeys[device.Key]; ok {
+			v.add(path+".key", "duplicate device key, it is also used by devices[%d]", index)
+		} else {
+			deviceKeys[device.Key] = i
+		}
+		if !util.IsValidHttpUrl(device.BarkBaseUrl) {
+			v.add(path+".barkBaseUrl", "'%s' is not a valid http or https url", device.BarkBaseUrl)
+		}
+		if device.IsDefault {
+			if defaultDeviceIndex >= 0 {
+				v.add(path+".isDefault", "devices[%d] is already the default device", defaultDeviceIndex)
+			} else {
+				defaultDeviceIndex = i
+			}
+		}
+		if device.Encryption != nil {
+			if err := device.Encryption.Validate(); err != nil {
+				v.add(path+".encryption", "%s", err.Error())
+			}
+		}
+		if device.PushOptions != nil {
+			if err := device.PushOptions.Validate(); err != nil {
+				v.add(path+".pushOptions", "%s", err.Error())
+			}
+		}
+		if !device.GetSecretGuard().IsValid() {
+			v.add(path+".secretGuard", "unsupported secret guard '%s', must be one of confirm, allow, block and encrypt", device.SecretGuard)
+		} else if device.SecretGuard == SecretGuardAllowIfEncrypted && device.Encryption == nil {
+			v.add(path+".secretGuard", "secret guard 'encrypt' requires the encryption of the device")
+		}
+	}
+}
+
+func (c *Config) validateProfiles(v *validator) {
+	profileNames := make(map[string]int)
+	for i, profile := range c.Profiles {
+		path := fmt.Sprintf("profiles[%d]", i)
+		// Init is called on a copy so that validating has no side effect
+		profileCopy := *profile
+		if err := profileCopy.Init(); err != nil {
+			v.add(path, "%s", err.Error())
+		}
+		if index, ok := profileNames[profile.Name]; ok && profile.Name != "" {
+			v.add(path+".name", "duplicate profile name '%s', it is also used by profiles[%d]", profile.Name, index)
+		} else {
+			profileNames[profile.Name] = i
+		}
+	}
+}
+
+func (c *Config) validateHotkeys(v *validator) {
+	if c.Hotkeys == nil {
+		return
+	}
+	checkHotkey := func(path string, spec string) {
+		if spec == "" {
+			return
+		}
+		if _, err := hotkeys.Parse(spec); err != nil {
+			v.add(path, "%s", err.Error())
+		}
+	}
+	checkHotkey("hotkeys.defaultDevice", c.Hotkeys.DefaultDevice)
+	checkHotkey("hotkeys.allDevices", c.Hotkeys.AllDevices)
+	deviceNames := make([]string, 0, len(c.Hotkeys.Devices))
+	for deviceName := range c.Hotkeys.Devices {
+		deviceNames = append(deviceNames, deviceName)
+	}
+	sort.Strings(deviceNames)
+	for _, deviceName := range deviceNames {
+		path := "hotkeys.devices." + deviceName
+		if c.GetDevice(deviceName) == nil {
+			v.add(path, "device '%s' does not exist", deviceName)
+		}
+		checkHotkey(path, c.Hotkeys.Devices[deviceName])
+	}
+}
+
+// checkFileWritable checks whether filePath can be opened for appending,
+// the file is removed if it does not exist before.
+func checkFileWritable(filePath string) error {
+	if filePath == "" {
+		return errors.New("path is empty")
+	}
+	_, statErr := os.Stat(filePath)
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_ = file.Close()
+	if os.IsNotExist(statErr) {
+		_ = os.Remove(filePath)
+	}
+	return nil
+}
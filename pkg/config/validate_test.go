@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	configFilePath := filepath.Join(dir, "config.json")
+	assert.Nil(t, os.WriteFile(configFilePath, []byte(`{
+  "version": "1.0.3",
+  "enableLog": true,
+  "logFilePath": "bark-tray.log",
+  "timeout": 0,
+  "devices": [
+    {
+      "name": "iPhone",
+      "barkBaseUrl": "https://api.day.app",
+      "key": "key1",
+      "isDefault": true
+    },
+    {
+      "name": "iPhone",
+      "barkBaseUrl": "ftp://api.day.app",
+      "key": "key1",
+      "isDefault": true,
+      "pushOptions": {"sound": "unknown", "colour": "red"}
+    }
+  ],
+  "profiles": [
+    {"name": "Search", "url": "{{.Text", "level": "passive"}
+  ],
+  "groups": [
+    {"name": "Family", "devices": ["iPhone", "iPad", "iPhone"]},
+    {"name": "Family", "devices": []}
+  ],
+  "hotkeys": {"devices": {"iPad": "Ctrl+Alt+2"}},
+  "unknownField": 1
+}`), 0644))
+	config, err := LoadConfig(configFilePath)
+	assert.Nil(t, err)
+	config.LogFilePath = filepath.Join(dir, "bark-tray.log")
+
+	err = config.Validate()
+	var validationErrors ValidationErrors
+	assert.True(t, errors.As(err, &validationErrors))
+	var messages []string
+	for _, validationError := range validationErrors {
+		messages = append(messages, validationError.Error())
+	}
+	assert.Equal(t, []string{
+		"timeout (line 5): must be between 1 and 300 seconds, got 0",
+		"devices[1].name (line 14): duplicate device name 'iPhone', it is also used by devices[0]",
+		"devices[1].barkBaseUrl (line 15): 'ftp://api.day.app' is not a valid http or https url",
+		"devices[1].key (line 16): duplicate device key, it is also used by devices[0]",
+		"devices[1].isDefault (line 17): devices[0] is already the default device",
+		"devices[1].pushOptions.colour (line 18): unknown field",
+		"devices[1].pushOptions (line 18): unsupported push sound 'unknown'",
+		"profiles[0] (line 22): invalid url template: template: url:1: unclosed action",
+		"groups[0].devices[1] (line 25): device 'iPad' does not exist",
+		"groups[0].devices[2] (line 25): device 'iPhone' is already in the group as devices[0]",
+		"groups[1].name (line 26): duplicate group name 'Family', it is also used by groups[0]",
+		"groups[1].devices (line 26): group 'Family' has no device",
+		"hotkeys.devices.iPad (line 28): device 'iPad' does not exist",
+		"unknownField (line 29): unknown field",
+	}, messages)
+	// The log file is not left behind by the writability check
+	assert.False(t, fileExists(config.LogFilePath))
+
+	config.LogFilePath = filepath.Join(dir, "missing", "bark-tray.log")
+	config.Timeout = 5
+	config.Devices = config.Devices[:1]
+	config.Profiles = nil
+	config.Groups = nil
+	config.Hotkeys = nil
+	config.source = nil
+	config.migratedSource = nil
+	err = config.Validate()
+	assert.True(t, errors.As(err, &validationErrors))
+	assert.Len(t, validationErrors, 1)
+	assert.Equal(t, "logFilePath", validationErrors[0].Path)
+
+	config.EnableLog = false
+	assert.Nil(t, config.Validate())
+}
+
+func fileExists(filePath string) bool {
+	_, err := os.Stat(filePath)
+	return err == nil
+}
+
+func TestLoadConfigSyntaxError(t *testing.T) {
+	configFilePath := filepath.Join(t.TempDir(), "config.json")
+	assert.Nil(t, os.WriteFile(configFilePath, []byte("{\n  \"timeout\": 5,\n  \"devices\": [,]\n}"), 0644))
+	_, err := LoadConfig(configFilePath)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "line 3: ")
+
+	assert.Nil(t, os.WriteFile(configFilePath, []byte("{\n  \"timeout\": \"5\"\n}"), 0644))
+	_, err = LoadConfig(configFilePath)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "line 2: ")
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"strconv"
+)
+
+// The config file is converted between the formats through yaml.Node,
+// which keeps the order of the keys and the comments.
+
+// yamlToNode parses the YAML data, and returns the root node of the document.
+func yamlToNode(data []byte) (*yaml.Node, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil, errors.New("the YAML document is empty")
+	}
+	return document.Content[0], nil
+}
+
+// nodeToYaml encodes node as a YAML document indented by 2 spaces.
+func nodeToYaml(node *yaml.Node) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// jsonToNode parses the JSON data into a node, the numbers are kept as they are.
+func jsonToNode(data []byte) (*yaml.Node, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	node, err := decodeJsonNode(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid JSON: unexpected data after the top-level value")
+	}
+	return node, nil
+}
+
+func decodeJsonNode(decoder *json.Decoder) (*yaml.Node, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch value := token.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if value == '{' {
+			node.Kind = yaml.MappingNode
+			node.Tag = "!!map"
+		}
+		for decoder.More() {
+			if node.Kind == yaml.MappingNode {
+				token, err = decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, scalarNode("!!str", token.(string)))
+			}
+			child, err := decodeJsonNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		// The closing delimiter
+		if _, err = decoder.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return scalarNode("!!str", value), nil
+	case json.Number:
+		if _, err = strconv.ParseInt(value.String(), 10, 64); err == nil {
+			return scalarNode("!!int", value.String()), nil
+		}
+		return scalarNode("!!float", value.String()), nil
+	case bool:
+		return scalarNode("!!bool", strconv.FormatBool(value)), nil
+	case nil:
+		return scalarNode("!!null", "null"), nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", token)
+}
+
+func scalarNode(tag string, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+// nodeToJson encodes node as compact JSON, keeping the order of the keys.
+func nodeToJson(node *yaml.Node) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := writeJsonNode(&buffer, node); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func writeJsonNode(buffer *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return writeJsonNode(buffer, node.Alias)
+	case yaml.MappingNode:
+		buffer.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode := node.Content[i]
+			if keyNode.Kind != yaml.ScalarNode || keyNode.Tag == "!!merge" {
+				return fmt.Errorf("line %d: unsupported key, the keys must be strings", keyNode.Line)
+			}
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			keyBytes, err := json.Marshal(keyNode.Value)
+			if err != nil {
+				return err
+			}
+			buffer.Write(keyBytes)
+			buffer.WriteByte(':')
+			if err = writeJsonNode(buffer, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	case yaml.SequenceNode:
+		buffer.WriteByte('[')
+		for i, child := range node.Content {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeJsonNode(buffer, child); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	case yaml.ScalarNode:
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		valueBytes, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", node.Line, err.Error())
+		}
+		buffer.Write(valueBytes)
+	default:
+		return fmt.Errorf("line %d: unsupported YAML node", node.Line)
+	}
+	return nil
+}
+
+// yamlLines records the line numbers of node and its children into lines, path is the path of node.
+func yamlLines(node *yaml.Node, path string, lines sourceLines) {
+	if path != "" {
+		if _, ok := lines[path]; !ok {
+			lines[path] = node.Line
+		}
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyPath := node.Content[i].Value
+			if path != "" {
+				keyPath = path + "." + keyPath
+			}
+			// The line of the key, as the value may start on the next line
+			lines[keyPath] = node.Content[i].Line
+			yamlLines(node.Content[i+1], keyPath, lines)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			yamlLines(child, fmt.Sprintf("%s[%d]", path, i), lines)
+		}
+	}
+}
+
+// copyYamlComments copies the comments in src to the same values in dst, the items
+// of a sequence are matched by their names if they have, or by their indexes.
+func copyYamlComments(dst *yaml.Node, src *yaml.Node) {
+	dst.HeadComment = src.HeadComment
+	dst.LineComment = src.LineComment
+	dst.FootComment = src.FootComment
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			for j := 0; j+1 < len(src.Content); j += 2 {
+				if dst.Content[i].Value == src.Content[j].Value {
+					copyYamlComments(dst.Content[i], src.Content[j])
+					copyYamlComments(dst.Content[i+1], src.Content[j+1])
+					break
+				}
+			}
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		for i, dstItem := range dst.Content {
+			name, hasName := mappingValue(dstItem, "name")
+			for j, srcItem := range src.Content {
+				srcName, srcHasName := mappingValue(srcItem, "name")
+				if hasName && srcHasName && name == srcName || !hasName && i == j {
+					copyYamlComments(dstItem, srcItem)
+					break
+				}
+			}
+		}
+	}
+}
+
+// mappingValue returns the scalar value of key in the mapping node.
+func mappingValue(node *yaml.Node, key string) (string, bool) {
+	if node.Kind != yaml.MappingNode {
+		return "", false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key && node.Content[i+1].Kind == yaml.ScalarNode {
+			return node.Content[i+1].Value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,38 @@
+package dispatcher
+
+import "sync"
+
+// Dispatcher runs jobs concurrently with a bounded number of workers.
+// Jobs may dispatch other jobs, since Go never blocks the caller.
+type Dispatcher struct {
+	workers chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New returns a Dispatcher that runs at most maxWorkers jobs at the same time,
+// maxWorkers less than 1 is treated as 1.
+func New(maxWorkers int) *Dispatcher {
+	if maxWorkers < 1 {
+		maxWorkers = 1
+	}
+	return &Dispatcher{
+		workers: make(chan struct{}, maxWorkers),
+	}
+}
+
+// Go schedules job to run once a worker is available.
+func (d *Dispatcher) Go(job func()) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.workers <- struct{}{}
+		defer func() { <-d.workers }()
+		job()
+	}()
+}
+
+// Wait blocks until all dispatched jobs, including the jobs
+// dispatched by other jobs, have finished.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
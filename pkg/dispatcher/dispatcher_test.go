@@ -0,0 +1,47 @@
+package dispatcher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatcherBoundsWorkers(t *testing.T) {
+	d := New(3)
+	var running, maxRunning, finished int32
+	for i := 0; i < 20; i++ {
+		d.Go(func() {
+			current := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&finished, 1)
+		})
+	}
+	d.Wait()
+	assert.Equal(t, int32(20), finished)
+	assert.LessOrEqual(t, maxRunning, int32(3))
+}
+
+func TestDispatcherNestedJobs(t *testing.T) {
+	d := New(1)
+	var finished int32
+	for i := 0; i < 5; i++ {
+		d.Go(func() {
+			for j := 0; j < 3; j++ {
+				d.Go(func() {
+					atomic.AddInt32(&finished, 1)
+				})
+			}
+			atomic.AddInt32(&finished, 1)
+		})
+	}
+	d.Wait()
+	assert.Equal(t, int32(20), finished)
+}
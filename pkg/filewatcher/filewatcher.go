@@ -0,0 +1,93 @@
+package filewatcher
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Watcher calls a function when a file is changed. The directory of the file
+// is watched instead of the file itself, so that the changes made by editors
+// which replace the file, rather than write to it, are not missed.
+type Watcher struct {
+	filePath  string
+	debounce  time.Duration
+	onChange  func()
+	fsWatcher *fsnotify.Watcher
+	stopOnce  sync.Once
+	done      chan struct{}
+}
+
+// Watch starts watching filePath, onChange is called once the file has not
+// been changed for debounce, so that a burst of writes triggers a single call.
+func Watch(filePath string, debounce time.Duration, onChange func()) (*Watcher, error) {
+	absFilePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err = fsWatcher.Add(filepath.Dir(absFilePath)); err != nil {
+		_ = fsWatcher.Close()
+		return nil, err
+	}
+	w := &Watcher{
+		filePath:  absFilePath,
+		debounce:  debounce,
+		onChange:  onChange,
+		fsWatcher: fsWatcher,
+		done:      make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Stop stops watching, onChange will not be called after it returns.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		_ = w.fsWatcher.Close()
+		<-w.done
+	})
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			}
+			if filepath.Clean(event.Name) != w.filePath || event.Op == fsnotify.Chmod {
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(w.debounce)
+			} else {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(w.debounce)
+			}
+			timerC = timer.C
+		case _, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+		case <-timerC:
+			timerC = nil
+			w.onChange()
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package filewatcher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "config.json")
+	assert.Nil(t, os.WriteFile(filePath, []byte("{}"), 0644))
+
+	var changes int32
+	watcher, err := Watch(filePath, 100*time.Millisecond, func() {
+		atomic.AddInt32(&changes, 1)
+	})
+	assert.Nil(t, err)
+	defer watcher.Stop()
+
+	// Other files in the directory are ignored
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "outbox.json"), []byte("[]"), 0644))
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&changes))
+
+	// A burst of writes triggers a single call
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, os.WriteFile(filePath, []byte(`{"timeout": 5}`), 0644))
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&changes) == 1
+	}, 2*time.Second, 20*time.Millisecond)
+
+	// Replacing the file is detected
+	tempFilePath := filepath.Join(dir, "config.json.tmp")
+	assert.Nil(t, os.WriteFile(tempFilePath, []byte(`{"timeout": 10}`), 0644))
+	assert.Nil(t, os.Rename(tempFilePath, filePath))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&changes) == 2
+	}, 2*time.Second, 20*time.Millisecond)
+
+	watcher.Stop()
+	assert.Nil(t, os.WriteFile(filePath, []byte("{}"), 0644))
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&changes))
+}
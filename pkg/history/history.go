@@ -0,0 +1,293 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/sensitive"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// The modes of the history.
+const (
+	// ModeFull records the messages as they are sent, defaults to it.
+	ModeFull = "full"
+	// ModeRedacted records only the devices, times and results, but not the messages.
+	ModeRedacted = "redacted"
+	// ModeOff records nothing.
+	ModeOff = "off"
+)
+
+const (
+	defaultMaxEntries = 50
+	maxMaxEntries     = 1000
+)
+
+// Options is the setting of the push history.
+type Options struct {
+	// Mode is one of ModeFull, ModeRedacted and ModeOff, defaults to ModeFull.
+	Mode string `json:"mode"`
+	// MaxEntries is the maximum number of entries kept, the oldest entries
+	// are removed when it is exceeded. Defaults to 50.
+	MaxEntries int `json:"maxEntries"`
+	// Redact is a list of regular expressions, the messages matching any of them
+	// are recorded as in ModeRedacted.
+	Redact []string `json:"redact"`
+}
+
+// GetMode returns Options.Mode, or ModeFull if it is not set.
+func (o *Options) GetMode() string {
+	if o == nil || o.Mode == "" {
+		return ModeFull
+	}
+	return o.Mode
+}
+
+// GetMaxEntries returns Options.MaxEntries, or the default if it is not set.
+func (o *Options) GetMaxEntries() int {
+	if o == nil || o.MaxEntries == 0 {
+		return defaultMaxEntries
+	}
+	return o.MaxEntries
+}
+
+// Policy decides what is recorded in the history.
+type Policy struct {
+	mode   string
+	redact []*regexp.Regexp
+}
+
+// NewPolicy compiles the options, which may be nil for the defaults.
+func NewPolicy(options *Options) (*Policy, error) {
+	policy := &Policy{mode: options.GetMode()}
+	switch policy.mode {
+	case ModeFull, ModeRedacted, ModeOff:
+	default:
+		return nil, fmt.Errorf("unsupported mode '%s', must be one of full, redacted and off", policy.mode)
+	}
+	if maxEntries := options.GetMaxEntries(); maxEntries < 1 || maxEntries > maxMaxEntries {
+		return nil, fmt.Errorf("maxEntries must be between 1 and %d, got %d", maxMaxEntries, maxEntries)
+	}
+	if options != nil {
+		for _, pattern := range options.Redact {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid redact pattern '%s': %s", pattern, err.Error())
+			}
+			policy.redact = append(policy.redact, re)
+		}
+	}
+	return policy, nil
+}
+
+// IsEnabled reports whether anything is recorded.
+func (p *Policy) IsEnabled() bool {
+	return p.mode != ModeOff
+}
+
+// NewEntry returns the entry of pushRequest sent to the devices in deliveries,
+// the message is left out if it should be redacted.
+func (p *Policy) NewEntry(pushRequest *bark.PushRequest, deliveries []Delivery) *Entry {
+	entry := &Entry{Deliveries: deliveries}
+	if p.shouldRedact(pushRequest) {
+		entry.Summary = fmt.Sprintf("[redacted, %d characters]", len([]rune(pushRequest.Body)))
+		return entry
+	}
+	entryPushRequest := *pushRequest
+	entryPushRequest.DeviceKey = ""
+	entryPushRequest.DeviceKeys = nil
+	entry.PushRequest = &entryPushRequest
+	entry.Summary = pushRequest.Body
+	return entry
+}
+
+// shouldRedact reports whether the message of pushRequest is left out, which is always the case
+// if it contains a secret found by the sensitive package, whatever the mode is.
+func (p *Policy) shouldRedact(pushRequest *bark.PushRequest) bool {
+	if p.mode == ModeRedacted {
+		return true
+	}
+	if sensitive.Contains(pushRequest.Text()) {
+		return true
+	}
+	for _, re := range p.redact {
+		if re.MatchString(pushRequest.Body) || re.MatchString(pushRequest.Title) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is the result of sending a message to a device.
+type Delivery struct {
+	DeviceName string `json:"deviceName"`
+	// Error is empty if the message is sent successfully.
+	Error string `json:"error,omitempty"`
+	// Response is the message in the response of the Bark server if the message is sent successfully.
+	Response string `json:"response,omitempty"`
+}
+
+// Entry is a message sent to one or more devices.
+type Entry struct {
+	Id     string    `json:"id"`
+	SentAt time.Time `json:"sentAt"`
+	// Summary is the body of the message, or a placeholder if the message is redacted.
+	Summary string `json:"summary"`
+	// PushRequest is the message without the device keys, it is nil if the message is redacted.
+	PushRequest *bark.PushRequest `json:"pushRequest,omitempty"`
+	Deliveries  []Delivery        `json:"deliveries"`
+}
+
+// IsRedacted reports whether the message is left out of the entry.
+func (e *Entry) IsRedacted() bool {
+	return e.PushRequest == nil
+}
+
+// FailedCount returns the number of devices the message failed to be sent to.
+func (e *Entry) FailedCount() int {
+	failedCount := 0
+	for _, delivery := range e.Deliveries {
+		if delivery.Error != "" {
+			failedCount++
+		}
+	}
+	return failedCount
+}
+
+// History stores the most recent sent messages in a file.
+type History struct {
+	mu         sync.Mutex
+	filePath   string
+	maxEntries int
+	entries    []*Entry
+	lastId     int64
+	onChange   func()
+	now        func() time.Time
+}
+
+// Open loads the history from filePath, the file will be created
+// when the first entry is added if it does not exist.
+func Open(filePath string, maxEntries int) (*History, error) {
+	h := &History{
+		filePath:   filePath,
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+	historyFileBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(historyFileBytes, &h.entries)
+	if err != nil {
+		return nil, err
+	}
+	h.trim()
+	return h, nil
+}
+
+// SetOnChange sets the function called after the entries are changed.
+func (h *History) SetOnChange(onChange func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onChange = onChange
+}
+
+// SetMaxEntries sets the maximum number of entries, the oldest entries beyond it are removed.
+func (h *History) SetMaxEntries(maxEntries int) error {
+	h.mu.Lock()
+	h.maxEntries = maxEntries
+	if len(h.entries) <= maxEntries {
+		h.mu.Unlock()
+		return nil
+	}
+	h.trim()
+	err := h.save()
+	h.mu.Unlock()
+	h.changed()
+	return err
+}
+
+// Add adds entry to the history, its Id and SentAt are set by Add.
+func (h *History) Add(entry *Entry) error {
+	h.mu.Lock()
+	now := h.now()
+	id := now.UnixNano()
+	if id <= h.lastId {
+		id = h.lastId + 1
+	}
+	h.lastId = id
+	entry.Id = fmt.Sprintf("%d", id)
+	entry.SentAt = now
+	h.entries = append(h.entries, entry)
+	h.trim()
+	err := h.save()
+	h.mu.Unlock()
+	h.changed()
+	return err
+}
+
+// Entries returns a copy of all entries in the order they were added.
+func (h *History) Entries() []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := make([]Entry, len(h.entries))
+	for i, entry := range h.entries {
+		entries[i] = *entry
+	}
+	return entries
+}
+
+// Get returns a copy of the entry with id, ok is false if it does not exist.
+func (h *History) Get(id string) (entry Entry, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, storedEntry := range h.entries {
+		if storedEntry.Id == id {
+			return *storedEntry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Clear removes all entries.
+func (h *History) Clear() error {
+	h.mu.Lock()
+	h.entries = nil
+	err := h.save()
+	h.mu.Unlock()
+	h.changed()
+	return err
+}
+
+// trim removes the oldest entries beyond maxEntries.
+func (h *History) trim() {
+	if len(h.entries) > h.maxEntries {
+		h.entries = append([]*Entry(nil), h.entries[len(h.entries)-h.maxEntries:]...)
+	}
+}
+
+func (h *History) changed() {
+	h.mu.Lock()
+	onChange := h.onChange
+	h.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// save writes the entries to the history file, the file may contain
+// sensitive clipboard content, so it is only readable by the current user.
+func (h *History) save() error {
+	historyFileBytes, err := json.MarshalIndent(h.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(h.filePath, historyFileBytes, 0600)
+}
@@ -0,0 +1,101 @@
+package hotkeys
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Modifier is a modifier key of a hotkey.
+type Modifier string
+
+const (
+	ModCtrl  Modifier = "Ctrl"
+	ModShift Modifier = "Shift"
+	// ModAlt is the Alt key, or the Option key on macOS.
+	ModAlt Modifier = "Alt"
+	// ModSuper is the Windows key, or the Command key on macOS.
+	ModSuper Modifier = "Super"
+)
+
+// keyNames maps the lower case key names accepted by Parse to the normalized key names.
+var keyNames = map[string]string{
+	"space": "Space", "return": "Enter", "enter": "Enter", "escape": "Esc", "esc": "Esc",
+	"delete": "Delete", "tab": "Tab", "left": "Left", "right": "Right", "up": "Up", "down": "Down",
+}
+
+func init() {
+	for c := 'A'; c <= 'Z'; c++ {
+		keyNames[strings.ToLower(string(c))] = string(c)
+	}
+	for c := '0'; c <= '9'; c++ {
+		keyNames[string(c)] = string(c)
+	}
+	for i := 1; i <= 20; i++ {
+		keyNames[fmt.Sprintf("f%d", i)] = fmt.Sprintf("F%d", i)
+	}
+}
+
+// Binding is a parsed hotkey spec such as "Ctrl+Alt+B".
+type Binding struct {
+	// Modifiers are sorted and deduplicated.
+	Modifiers []Modifier
+	// Key is the normalized key name, e.g. "B", "F12" and "Enter".
+	Key string
+}
+
+// Parse parses a hotkey spec, which is one or more modifiers and a key joined by "+",
+// e.g. "Ctrl+Alt+B". Modifiers are Ctrl, Shift, Alt (or Option) and Super (or Win, Cmd),
+// keys are A-Z, 0-9, F1-F20, Space, Enter, Esc, Delete, Tab and the arrow keys.
+// Names are case-insensitive.
+func Parse(spec string) (*Binding, error) {
+	parts := strings.Split(spec, "+")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid hotkey '%s': it must be at least one modifier and a key", spec)
+	}
+	binding := &Binding{}
+	seen := make(map[Modifier]bool)
+	for _, part := range parts[:len(parts)-1] {
+		modifier, ok := parseModifier(strings.TrimSpace(part))
+		if !ok {
+			return nil, fmt.Errorf("invalid hotkey '%s': unknown modifier '%s'", spec, part)
+		}
+		if !seen[modifier] {
+			seen[modifier] = true
+			binding.Modifiers = append(binding.Modifiers, modifier)
+		}
+	}
+	sort.Slice(binding.Modifiers, func(i, j int) bool {
+		return binding.Modifiers[i] < binding.Modifiers[j]
+	})
+	key, ok := keyNames[strings.ToLower(strings.TrimSpace(parts[len(parts)-1]))]
+	if !ok {
+		return nil, fmt.Errorf("invalid hotkey '%s': unknown key '%s'", spec, parts[len(parts)-1])
+	}
+	binding.Key = key
+	return binding, nil
+}
+
+func parseModifier(name string) (Modifier, bool) {
+	switch strings.ToLower(name) {
+	case "ctrl", "control":
+		return ModCtrl, true
+	case "shift":
+		return ModShift, true
+	case "alt", "option":
+		return ModAlt, true
+	case "super", "win", "cmd", "command", "meta":
+		return ModSuper, true
+	}
+	return "", false
+}
+
+// String returns the normalized spec, e.g. "Alt+Ctrl+B",
+// bindings with the same string are the same hotkey.
+func (b *Binding) String() string {
+	parts := make([]string, 0, len(b.Modifiers)+1)
+	for _, modifier := range b.Modifiers {
+		parts = append(parts, string(modifier))
+	}
+	return strings.Join(append(parts, b.Key), "+")
+}
@@ -0,0 +1,10 @@
+package hotkeys
+
+import "golang.design/x/hotkey"
+
+var platformModifiers = map[Modifier]hotkey.Modifier{
+	ModCtrl:  hotkey.ModCtrl,
+	ModShift: hotkey.ModShift,
+	ModAlt:   hotkey.ModOption,
+	ModSuper: hotkey.ModCmd,
+}
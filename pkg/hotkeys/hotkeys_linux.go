@@ -0,0 +1,88 @@
+package hotkeys
+
+import (
+	"fmt"
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/keybind"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"strings"
+	"sync"
+)
+
+// On Linux the hotkeys are grabbed on the root window of the X server,
+// the connection is made and the event loop is started on the first
+// registration, and they are kept for later registrations.
+var (
+	mu        sync.Mutex
+	xUtil     *xgbutil.XUtil
+	xUtilErr  error
+	xUtilOnce sync.Once
+	running   bool
+)
+
+var xModifiers = map[Modifier]string{
+	ModCtrl:  "Control",
+	ModShift: "Shift",
+	ModAlt:   "Mod1",
+	ModSuper: "Mod4",
+}
+
+// xKeyNames maps the key names which differ from the X keysym names.
+var xKeyNames = map[string]string{
+	"Space": "space",
+	"Enter": "Return",
+	"Esc":   "Escape",
+}
+
+// xKeyString returns the binding in the format of keybind.ParseString, e.g. "Control-Mod1-b".
+func xKeyString(binding *Binding) string {
+	parts := make([]string, 0, len(binding.Modifiers)+1)
+	for _, modifier := range binding.Modifiers {
+		parts = append(parts, xModifiers[modifier])
+	}
+	key, ok := xKeyNames[binding.Key]
+	if !ok {
+		key = binding.Key
+		if len(key) == 1 {
+			key = strings.ToLower(key)
+		}
+	}
+	return strings.Join(append(parts, key), "-")
+}
+
+// Register registers binding as a global hotkey, onKeyDown is called every time it is pressed.
+// An error is returned if the hotkey has been registered by another application.
+func Register(binding *Binding, onKeyDown func()) error {
+	xUtilOnce.Do(func() {
+		xUtil, xUtilErr = xgbutil.NewConn()
+		if xUtilErr == nil {
+			keybind.Initialize(xUtil)
+		}
+	})
+	if xUtilErr != nil {
+		return fmt.Errorf("failed to connect to the X server: %s", xUtilErr.Error())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	err := keybind.KeyPressFun(func(X *xgbutil.XUtil, e xevent.KeyPressEvent) {
+		go onKeyDown()
+	}).Connect(xUtil, xUtil.RootWin(), xKeyString(binding), true)
+	if err != nil {
+		return err
+	}
+	if !running {
+		running = true
+		go xevent.Main(xUtil)
+	}
+	return nil
+}
+
+// UnregisterAll unregisters all hotkeys registered by Register.
+func UnregisterAll() {
+	mu.Lock()
+	defer mu.Unlock()
+	if xUtil == nil {
+		return
+	}
+	keybind.Detach(xUtil, xUtil.RootWin())
+}
@@ -0,0 +1,68 @@
+//go:build !linux
+
+package hotkeys
+
+import (
+	"fmt"
+	"golang.design/x/hotkey"
+	"sync"
+)
+
+var (
+	mu         sync.Mutex
+	registered []*hotkey.Hotkey
+)
+
+var keys = map[string]hotkey.Key{
+	"Space": hotkey.KeySpace, "Enter": hotkey.KeyReturn, "Esc": hotkey.KeyEscape,
+	"Delete": hotkey.KeyDelete, "Tab": hotkey.KeyTab,
+	"Left": hotkey.KeyLeft, "Right": hotkey.KeyRight, "Up": hotkey.KeyUp, "Down": hotkey.KeyDown,
+	"0": hotkey.Key0, "1": hotkey.Key1, "2": hotkey.Key2, "3": hotkey.Key3, "4": hotkey.Key4,
+	"5": hotkey.Key5, "6": hotkey.Key6, "7": hotkey.Key7, "8": hotkey.Key8, "9": hotkey.Key9,
+	"A": hotkey.KeyA, "B": hotkey.KeyB, "C": hotkey.KeyC, "D": hotkey.KeyD, "E": hotkey.KeyE,
+	"F": hotkey.KeyF, "G": hotkey.KeyG, "H": hotkey.KeyH, "I": hotkey.KeyI, "J": hotkey.KeyJ,
+	"K": hotkey.KeyK, "L": hotkey.KeyL, "M": hotkey.KeyM, "N": hotkey.KeyN, "O": hotkey.KeyO,
+	"P": hotkey.KeyP, "Q": hotkey.KeyQ, "R": hotkey.KeyR, "S": hotkey.KeyS, "T": hotkey.KeyT,
+	"U": hotkey.KeyU, "V": hotkey.KeyV, "W": hotkey.KeyW, "X": hotkey.KeyX, "Y": hotkey.KeyY,
+	"Z": hotkey.KeyZ,
+	"F1": hotkey.KeyF1, "F2": hotkey.KeyF2, "F3": hotkey.KeyF3, "F4": hotkey.KeyF4, "F5": hotkey.KeyF5,
+	"F6": hotkey.KeyF6, "F7": hotkey.KeyF7, "F8": hotkey.KeyF8, "F9": hotkey.KeyF9, "F10": hotkey.KeyF10,
+	"F11": hotkey.KeyF11, "F12": hotkey.KeyF12, "F13": hotkey.KeyF13, "F14": hotkey.KeyF14, "F15": hotkey.KeyF15,
+	"F16": hotkey.KeyF16, "F17": hotkey.KeyF17, "F18": hotkey.KeyF18, "F19": hotkey.KeyF19, "F20": hotkey.KeyF20,
+}
+
+// Register registers binding as a global hotkey, onKeyDown is called every time it is pressed.
+// An error is returned if the hotkey has been registered by another application.
+func Register(binding *Binding, onKeyDown func()) error {
+	modifiers := make([]hotkey.Modifier, len(binding.Modifiers))
+	for i, modifier := range binding.Modifiers {
+		modifiers[i] = platformModifiers[modifier]
+	}
+	key, ok := keys[binding.Key]
+	if !ok {
+		return fmt.Errorf("unsupported key '%s'", binding.Key)
+	}
+	hk := hotkey.New(modifiers, key)
+	if err := hk.Register(); err != nil {
+		return err
+	}
+	mu.Lock()
+	registered = append(registered, hk)
+	mu.Unlock()
+	go func() {
+		for range hk.Keydown() {
+			go onKeyDown()
+		}
+	}()
+	return nil
+}
+
+// UnregisterAll unregisters all hotkeys registered by Register.
+func UnregisterAll() {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, hk := range registered {
+		_ = hk.Unregister()
+	}
+	registered = nil
+}
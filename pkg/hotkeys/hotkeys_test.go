@@ -0,0 +1,31 @@
+package hotkeys
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	binding, err := Parse("Ctrl+Alt+B")
+	assert.Nil(t, err)
+	assert.Equal(t, []Modifier{ModAlt, ModCtrl}, binding.Modifiers)
+	assert.Equal(t, "B", binding.Key)
+	assert.Equal(t, "Alt+Ctrl+B", binding.String())
+
+	sameBinding, err := Parse(" alt + control + control + b ")
+	assert.Nil(t, err)
+	assert.Equal(t, binding, sameBinding)
+
+	binding, err = Parse("Cmd+Shift+f12")
+	assert.Nil(t, err)
+	assert.Equal(t, "Shift+Super+F12", binding.String())
+
+	binding, err = Parse("Win+Return")
+	assert.Nil(t, err)
+	assert.Equal(t, "Super+Enter", binding.String())
+
+	for _, spec := range []string{"", "B", "Ctrl+", "Hyper+B", "Ctrl+PageUp"} {
+		_, err = Parse(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
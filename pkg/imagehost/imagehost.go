@@ -0,0 +1,149 @@
+package imagehost
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/httpClient"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+const (
+	// FileNamePlaceholder in Host.UploadUrl will be replaced by the file name of the image.
+	FileNamePlaceholder = "{filename}"
+	defaultFieldName    = "file"
+)
+
+// Host is a generic image host which accepts images via HTTP PUT or multipart POST.
+type Host struct {
+	// UploadUrl is the url to upload the image to,
+	// FileNamePlaceholder in it will be replaced by the file name of the image.
+	UploadUrl string `json:"uploadUrl"`
+	// Method is either PUT, which sends the image as the request body,
+	// or POST, which sends the image as a multipart form. Defaults to PUT.
+	Method string `json:"method"`
+	// FieldName is the form field name of the image for POST. Defaults to "file".
+	FieldName string `json:"fieldName"`
+	// Headers are extra headers sent with the upload request, e.g. Authorization.
+	Headers map[string]string `json:"headers"`
+	// UrlField is the dot-separated path of the image url in the JSON response, e.g. "data.url".
+	// If it is empty, the response body is used as the image url,
+	// or the upload url if the response body is empty.
+	UrlField string `json:"urlField"`
+}
+
+func (h *Host) method() string {
+	if h.Method == "" {
+		return http.MethodPut
+	}
+	return strings.ToUpper(h.Method)
+}
+
+// Validate checks if the image host setting is usable.
+func (h *Host) Validate() error {
+	if !util.IsValidHttpUrl(h.UploadUrl) {
+		return fmt.Errorf("invalid upload url '%s'", h.UploadUrl)
+	}
+	switch h.method() {
+	case http.MethodPut, http.MethodPost:
+	default:
+		return fmt.Errorf("unsupported upload method '%s'", h.Method)
+	}
+	return nil
+}
+
+// Upload uploads the image data with fileName and contentType, and returns the url of the image.
+func (h *Host) Upload(data []byte, fileName string, contentType string) (string, error) {
+	if err := h.Validate(); err != nil {
+		return "", err
+	}
+	uploadUrl := strings.ReplaceAll(h.UploadUrl, FileNamePlaceholder, url.PathEscape(fileName))
+	var request *http.Request
+	var err error
+	if h.method() == http.MethodPut {
+		request, err = http.NewRequest(http.MethodPut, uploadUrl, bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		request.Header.Set("Content-Type", contentType)
+	} else {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		fieldName := h.FieldName
+		if fieldName == "" {
+			fieldName = defaultFieldName
+		}
+		partHeader := make(textproto.MIMEHeader)
+		partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, fieldName, fileName))
+		partHeader.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(partHeader)
+		if err != nil {
+			return "", err
+		}
+		if _, err = part.Write(data); err != nil {
+			return "", err
+		}
+		if err = writer.Close(); err != nil {
+			return "", err
+		}
+		request, err = http.NewRequest(http.MethodPost, uploadUrl, &body)
+		if err != nil {
+			return "", err
+		}
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+	}
+	for key, value := range h.Headers {
+		request.Header.Set(key, value)
+	}
+
+	client := httpClient.MustGetHttpClient()
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return "", fmt.Errorf("failed to upload image: %s", response.Status)
+	}
+	return h.extractImageUrl(responseBody, uploadUrl)
+}
+
+// extractImageUrl extracts the image url from the response body according to Host.UrlField.
+func (h *Host) extractImageUrl(responseBody []byte, uploadUrl string) (string, error) {
+	if h.UrlField == "" {
+		imageUrl := strings.TrimSpace(string(responseBody))
+		if imageUrl == "" {
+			return uploadUrl, nil
+		}
+		if !util.IsValidHttpUrl(imageUrl) {
+			return "", fmt.Errorf("the image host responded with an invalid url")
+		}
+		return imageUrl, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(responseBody, &value); err != nil {
+		return "", fmt.Errorf("failed to parse the response of the image host: %s", err.Error())
+	}
+	for _, key := range strings.Split(h.UrlField, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("field '%s' not found in the response of the image host", h.UrlField)
+		}
+		value = object[key]
+	}
+	imageUrl, ok := value.(string)
+	if !ok || !util.IsValidHttpUrl(imageUrl) {
+		return "", fmt.Errorf("field '%s' in the response of the image host is not a valid url", h.UrlField)
+	}
+	return imageUrl, nil
+}
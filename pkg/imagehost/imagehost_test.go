@@ -0,0 +1,78 @@
+package imagehost
+
+import (
+	"github.com/LGiki/bark-tray/pkg/httpClient"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var testImage = []byte("\x89PNG\r\n\x1a\nfake image")
+
+func TestUploadPut(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/images/clipboard.png", r.URL.Path)
+		assert.Equal(t, "image/png", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, testImage, body)
+	}))
+	defer server.Close()
+	httpClient.Setup("Bark Tray Test", 5)
+
+	host := &Host{
+		UploadUrl: server.URL + "/images/" + FileNamePlaceholder,
+		Headers:   map[string]string{"Authorization": "Bearer token"},
+	}
+	imageUrl, err := host.Upload(testImage, "clipboard.png", "image/png")
+	assert.Nil(t, err)
+	assert.Equal(t, server.URL+"/images/clipboard.png", imageUrl)
+}
+
+func TestUploadPostMultipart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		file, header, err := r.FormFile("image")
+		assert.Nil(t, err)
+		assert.Equal(t, "clipboard.png", header.Filename)
+		body, err := io.ReadAll(file)
+		assert.Nil(t, err)
+		assert.Equal(t, testImage, body)
+		_, _ = w.Write([]byte(`{"data":{"url":"https://img.example.org/abc.png"}}`))
+	}))
+	defer server.Close()
+	httpClient.Setup("Bark Tray Test", 5)
+
+	host := &Host{
+		UploadUrl: server.URL + "/upload",
+		Method:    "post",
+		FieldName: "image",
+		UrlField:  "data.url",
+	}
+	imageUrl, err := host.Upload(testImage, "clipboard.png", "image/png")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://img.example.org/abc.png", imageUrl)
+}
+
+func TestUploadErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/denied" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{}}`))
+	}))
+	defer server.Close()
+	httpClient.Setup("Bark Tray Test", 5)
+
+	_, err := (&Host{UploadUrl: server.URL + "/denied"}).Upload(testImage, "clipboard.png", "image/png")
+	assert.NotNil(t, err)
+	_, err = (&Host{UploadUrl: server.URL + "/upload", Method: "POST", UrlField: "data.url"}).Upload(testImage, "clipboard.png", "image/png")
+	assert.NotNil(t, err)
+	assert.NotNil(t, (&Host{UploadUrl: server.URL, Method: "PATCH"}).Validate())
+	assert.NotNil(t, (&Host{UploadUrl: "ftp://example.org"}).Validate())
+}
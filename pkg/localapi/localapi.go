@@ -0,0 +1,307 @@
+package localapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListen = "127.0.0.1:7191"
+	// maxRequestBodySize is the maximum size of the body of a push request.
+	maxRequestBodySize = 1 << 20
+)
+
+// Options is the setting of the local HTTP API.
+type Options struct {
+	// Enabled is whether to start the server on startup.
+	Enabled bool `json:"enabled"`
+	// Listen is the address to listen on, which must be a loopback address.
+	// Defaults to "127.0.0.1:7191".
+	Listen string `json:"listen"`
+	// Token is the bearer token required by all endpoints except /health.
+	Token string `json:"token"`
+	// Metrics is whether to serve the push metrics at /metrics in the Prometheus text format.
+	Metrics bool `json:"metrics"`
+}
+
+// Validate checks whether the options are valid.
+func (o *Options) Validate() error {
+	if o.Token == "" {
+		return fmt.Errorf("token is empty")
+	}
+	host, _, err := net.SplitHostPort(o.GetListen())
+	if err != nil {
+		return fmt.Errorf("invalid listen address '%s': %s", o.Listen, err.Error())
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return fmt.Errorf("listen address '%s' is not a loopback address", o.Listen)
+		}
+	}
+	return nil
+}
+
+// GetListen returns Options.Listen, or the default address if it is not set.
+func (o *Options) GetListen() string {
+	if o.Listen == "" {
+		return defaultListen
+	}
+	return o.Listen
+}
+
+// Device is a device listed by GET /devices.
+type Device struct {
+	Name      string `json:"name"`
+	IsDefault bool   `json:"isDefault"`
+}
+
+// Result is the result of pushing to a device.
+type Result struct {
+	Device string `json:"device"`
+	// Error is empty if the push request is sent successfully.
+	Error string `json:"error,omitempty"`
+}
+
+// DevicesFunc returns the devices that can be pushed to.
+type DevicesFunc func() []Device
+
+// PushFunc sends pushRequest to the devices with deviceNames, which are known to exist.
+type PushFunc func(deviceNames []string, pushRequest *bark.PushRequest) []Result
+
+// MetricsFunc writes the push metrics to w in the Prometheus text format.
+type MetricsFunc func(w io.Writer) error
+
+// Server is the local HTTP API server, which provides the following endpoints:
+//
+//	GET /health returns {"status":"ok"}.
+//	GET /devices returns the devices.
+//	POST /push?device=NAME sends the request body to the device,
+//	device can be repeated, or replaced by all=true to send to all devices,
+//	the default device is used if neither is specified.
+//	GET /metrics returns the push metrics in the Prometheus text format if Options.Metrics is true.
+//
+// The body of POST /push is either a plain text message, whose first url is opened
+// when the notification is clicked, or a bark.PushRequest in JSON if the
+// Content-Type is application/json.
+type Server struct {
+	options    *Options
+	devices    DevicesFunc
+	push       PushFunc
+	metrics    MetricsFunc
+	httpServer *http.Server
+}
+
+// New creates a server with options, it is not started until Start is called.
+// metrics may be nil if the metrics are not available.
+func New(options *Options, devices DevicesFunc, push PushFunc, metrics MetricsFunc) (*Server, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	s := &Server{
+		options: options,
+		devices: devices,
+		push:    push,
+		metrics: metrics,
+	}
+	s.httpServer = &http.Server{
+		Addr:              options.GetListen(),
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s, nil
+}
+
+// Handler returns the http.Handler serving the endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.Handle("/devices", s.authorize(http.HandlerFunc(s.handleDevices)))
+	mux.Handle("/push", s.authorize(http.HandlerFunc(s.handlePush)))
+	if s.options.Metrics && s.metrics != nil {
+		mux.Handle("/metrics", s.authorize(http.HandlerFunc(s.handleMetrics)))
+	}
+	return mux
+}
+
+// Start starts listening and serves the requests in the background.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		_ = s.httpServer.Serve(listener)
+	}()
+	return nil
+}
+
+// Shutdown stops the server, waiting for the requests being handled to finish.
+func (s *Server) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + s.options.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	devices := s.devices()
+	if devices == nil {
+		devices = []Device{}
+	}
+	writeJson(w, http.StatusOK, devices)
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	deviceNames, status, err := s.resolveDevices(r)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	pushRequest, err := readPushRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	results := s.push(deviceNames, pushRequest)
+	status = http.StatusOK
+	for _, result := range results {
+		if result.Error != "" {
+			status = http.StatusBadGateway
+			break
+		}
+	}
+	writeJson(w, status, map[string][]Result{"results": results})
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var b bytes.Buffer
+	if err := s.metrics(&b); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b.Bytes())
+}
+
+// resolveDevices returns the names of the devices to push to according to the query parameters,
+// status is the HTTP status code to respond with if err is not nil.
+func (s *Server) resolveDevices(r *http.Request) (deviceNames []string, status int, err error) {
+	query := r.URL.Query()
+	devices := s.devices()
+	requestedNames := query["device"]
+	if query.Get("all") == "true" {
+		if len(requestedNames) > 0 {
+			return nil, http.StatusBadRequest, errors.New("device cannot be specified with all")
+		}
+		for _, device := range devices {
+			deviceNames = append(deviceNames, device.Name)
+		}
+		if len(deviceNames) == 0 {
+			return nil, http.StatusNotFound, errors.New("there is no device")
+		}
+		return deviceNames, 0, nil
+	}
+	if len(requestedNames) == 0 {
+		for _, device := range devices {
+			if device.IsDefault {
+				return []string{device.Name}, 0, nil
+			}
+		}
+		return nil, http.StatusNotFound, errors.New("no device specified and there is no default device")
+	}
+	for _, name := range requestedNames {
+		found := false
+		for _, device := range devices {
+			if device.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, http.StatusNotFound, fmt.Errorf("device '%s' not found", name)
+		}
+	}
+	return requestedNames, 0, nil
+}
+
+// readPushRequest reads the push request from the request body,
+// the device keys and the encryption fields in it are ignored.
+func readPushRequest(r *http.Request) (*bark.PushRequest, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the request body: %s", err.Error())
+	}
+	pushRequest := &bark.PushRequest{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err = json.Unmarshal(body, pushRequest); err != nil {
+			return nil, fmt.Errorf("invalid push request: %s", err.Error())
+		}
+		pushRequest.DeviceKey = ""
+		pushRequest.DeviceKeys = nil
+		pushRequest.Ciphertext = ""
+		pushRequest.Iv = ""
+		pushRequest.Body = strings.TrimSpace(pushRequest.Body)
+	} else {
+		// Same as the text pushed from the clipboard
+		pushRequest.Body = strings.TrimSpace(string(body))
+		pushRequest.Url = util.ExtractUrlFromText(pushRequest.Body)
+	}
+	if pushRequest.Body == "" {
+		return nil, errors.New("body is empty")
+	}
+	return pushRequest, nil
+}
+
+func writeJson(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJson(w, status, map[string]string{"error": message})
+}
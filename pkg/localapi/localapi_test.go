@@ -0,0 +1,125 @@
+package localapi
+
+import (
+	"encoding/json"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type pushCall struct {
+	deviceNames []string
+	pushRequest *bark.PushRequest
+}
+
+func newTestServer(t *testing.T) (*Server, *[]pushCall) {
+	var calls []pushCall
+	server, err := New(
+		&Options{Enabled: true, Token: "secret", Metrics: true},
+		func() []Device {
+			return []Device{{Name: "iPhone", IsDefault: true}, {Name: "iPad"}}
+		},
+		func(deviceNames []string, pushRequest *bark.PushRequest) []Result {
+			calls = append(calls, pushCall{deviceNames, pushRequest})
+			results := make([]Result, len(deviceNames))
+			for i, name := range deviceNames {
+				results[i] = Result{Device: name}
+				if name == "iPad" {
+					results[i].Error = "device offline"
+				}
+			}
+			return results
+		},
+		func(w io.Writer) error {
+			_, err := io.WriteString(w, "bark_tray_pushes_total{device=\"iPhone\"} 1\n")
+			return err
+		},
+	)
+	assert.Nil(t, err)
+	return server, &calls
+}
+
+func serve(server *Server, method string, target string, contentType string, body string, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.Nil(t, (&Options{Token: "secret"}).Validate())
+	assert.Nil(t, (&Options{Token: "secret", Listen: "localhost:8080"}).Validate())
+	assert.Nil(t, (&Options{Token: "secret", Listen: "[::1]:8080"}).Validate())
+	assert.NotNil(t, (&Options{}).Validate())
+	assert.NotNil(t, (&Options{Token: "secret", Listen: "0.0.0.0:8080"}).Validate())
+	assert.NotNil(t, (&Options{Token: "secret", Listen: "127.0.0.1"}).Validate())
+}
+
+func TestAuthorization(t *testing.T) {
+	server, _ := newTestServer(t)
+	assert.Equal(t, http.StatusOK, serve(server, http.MethodGet, "/health", "", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(server, http.MethodGet, "/devices", "", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(server, http.MethodGet, "/devices", "", "", "wrong").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(server, http.MethodPost, "/push", "", "hello", "").Code)
+}
+
+func TestDevices(t *testing.T) {
+	server, _ := newTestServer(t)
+	response := serve(server, http.MethodGet, "/devices", "", "", "secret")
+	assert.Equal(t, http.StatusOK, response.Code)
+	var devices []Device
+	assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &devices))
+	assert.Equal(t, []Device{{Name: "iPhone", IsDefault: true}, {Name: "iPad"}}, devices)
+}
+
+func TestPush(t *testing.T) {
+	server, calls := newTestServer(t)
+
+	response := serve(server, http.MethodPost, "/push", "text/plain", " see https://example.com \n", "secret")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, []string{"iPhone"}, (*calls)[0].deviceNames)
+	assert.Equal(t, "see https://example.com", (*calls)[0].pushRequest.Body)
+	assert.Equal(t, "https://example.com", (*calls)[0].pushRequest.Url)
+
+	response = serve(server, http.MethodPost, "/push?device=iPhone", "application/json",
+		`{"title":"Hi","body":"hello","device_key":"ignored","sound":"bell"}`, "secret")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, &bark.PushRequest{Title: "Hi", Body: "hello", Sound: "bell"}, (*calls)[1].pushRequest)
+
+	response = serve(server, http.MethodPost, "/push?all=true", "", "hello", "secret")
+	assert.Equal(t, http.StatusBadGateway, response.Code)
+	assert.Equal(t, []string{"iPhone", "iPad"}, (*calls)[2].deviceNames)
+	var results map[string][]Result
+	assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &results))
+	assert.Equal(t, []Result{{Device: "iPhone"}, {Device: "iPad", Error: "device offline"}}, results["results"])
+
+	assert.Equal(t, http.StatusNotFound, serve(server, http.MethodPost, "/push?device=Mac", "", "hello", "secret").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(server, http.MethodPost, "/push?device=iPad&all=true", "", "hello", "secret").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(server, http.MethodPost, "/push", "", "  ", "secret").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(server, http.MethodPost, "/push", "application/json", "{", "secret").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(server, http.MethodGet, "/push", "", "", "secret").Code)
+	assert.Len(t, *calls, 3)
+}
+
+func TestMetrics(t *testing.T) {
+	server, _ := newTestServer(t)
+	assert.Equal(t, http.StatusUnauthorized, serve(server, http.MethodGet, "/metrics", "", "", "").Code)
+	response := serve(server, http.MethodGet, "/metrics", "", "", "secret")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", response.Header().Get("Content-Type"))
+	assert.Equal(t, "bark_tray_pushes_total{device=\"iPhone\"} 1\n", response.Body.String())
+
+	disabledServer, err := New(&Options{Token: "secret"}, server.devices, server.push, server.metrics)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, serve(disabledServer, http.MethodGet, "/metrics", "", "", "secret").Code)
+}
@@ -1,78 +1,217 @@
 package logger
 
 import (
+	"fmt"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"os"
+	"sync"
+	"time"
 )
 
-var logger *zap.Logger
-
-func InitLogger(logFilePath string) error {
-	var err error
-	zapConfig := zap.Config{
-		Level:       zap.NewAtomicLevelAt(zap.InfoLevel),
-		Development: false,
-		Sampling: &zap.SamplingConfig{
-			Initial:    100,
-			Thereafter: 100,
-		},
-		Encoding: "json",
-		EncoderConfig: zapcore.EncoderConfig{
-			TimeKey:        "ts",
-			LevelKey:       "level",
-			NameKey:        "logger",
-			CallerKey:      "",
-			FunctionKey:    zapcore.OmitKey,
-			MessageKey:     "msg",
-			StacktraceKey:  "",
-			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    zapcore.LowercaseLevelEncoder,
-			EncodeTime:     zapcore.EpochTimeEncoder,
-			EncodeDuration: zapcore.SecondsDurationEncoder,
-			EncodeCaller:   zapcore.ShortCallerEncoder,
-		},
-		OutputPaths:       []string{logFilePath},
-		ErrorOutputPaths:  []string{"stderr", logFilePath},
-		DisableStacktrace: true,
-		DisableCaller:     true,
-	}
-	logger, err = zapConfig.Build()
-	return err
+const (
+	defaultMaxSizeMb  = 10
+	defaultMaxBackups = 5
+	// samplingFirst and samplingThereafter are the numbers of the same messages logged per second,
+	// the first samplingFirst messages are logged and every samplingThereafter-th after that.
+	samplingFirst      = 100
+	samplingThereafter = 100
+)
+
+// The encodings of the log file.
+const (
+	EncodingJson    = "json"
+	EncodingConsole = "console"
+)
+
+// Options is the setting of the log file.
+type Options struct {
+	// Level is the minimum level logged, one of debug, info, warn and error. Defaults to info.
+	Level string `json:"level"`
+	// Encoding is EncodingJson or EncodingConsole, defaults to EncodingJson.
+	Encoding string `json:"encoding"`
+	// DisableSampling logs all the messages, by default only the first 100 same messages
+	// per second and every 100th after that are logged.
+	DisableSampling bool `json:"disableSampling"`
+	// MaxSizeMb is the size in megabytes of the log file at which it is rotated, defaults to 10.
+	MaxSizeMb int `json:"maxSizeMb"`
+	// MaxBackups is the maximum number of rotated log files kept, defaults to 5.
+	MaxBackups int `json:"maxBackups"`
+	// MaxAgeDays is the maximum number of days the rotated log files are kept, 0 means no limit.
+	MaxAgeDays int `json:"maxAgeDays"`
+	// Compress is whether to compress the rotated log files with gzip.
+	Compress bool `json:"compress"`
 }
 
-func Info(msg string, fields ...zap.Field) {
-	if logger != nil {
-		logger.Info(msg, fields...)
+// Validate checks whether the options are valid.
+func (o *Options) Validate() error {
+	if _, err := o.getLevel(); err != nil {
+		return err
 	}
+	switch o.getEncoding() {
+	case EncodingJson, EncodingConsole:
+	default:
+		return fmt.Errorf("unsupported encoding '%s', must be json or console", o.Encoding)
+	}
+	if o.MaxSizeMb < 0 || o.MaxBackups < 0 || o.MaxAgeDays < 0 {
+		return fmt.Errorf("maxSizeMb, maxBackups and maxAgeDays must not be negative")
+	}
+	return nil
 }
 
-func Error(msg string, fields ...zap.Field) {
-	if logger != nil {
-		logger.Error(msg, fields...)
+func (o *Options) getLevel() (zapcore.Level, error) {
+	if o == nil || o.Level == "" {
+		return zapcore.InfoLevel, nil
+	}
+	level, err := zapcore.ParseLevel(o.Level)
+	if err != nil || level > zapcore.ErrorLevel {
+		return level, fmt.Errorf("unsupported level '%s', must be one of debug, info, warn and error", o.Level)
 	}
+	return level, nil
 }
 
-func Fatal(msg string, fields ...zap.Field) {
-	if logger != nil {
-		logger.Fatal(msg, fields...)
+func (o *Options) getEncoding() string {
+	if o == nil || o.Encoding == "" {
+		return EncodingJson
 	}
+	return o.Encoding
 }
 
-func Debug(msg string, fields ...zap.Field) {
-	if logger != nil {
-		logger.Debug(msg, fields...)
+var (
+	// mu guards logger and logFile, the log functions hold its read lock while writing,
+	// so that the log file is not closed under them by InitLogger or Close.
+	mu     sync.RWMutex
+	logger *zap.Logger
+	// logFile is the rotated log file of logger.
+	logFile *lumberjack.Logger
+)
+
+// InitLogger logs to the file at logFilePath with options, which may be nil for the defaults.
+func InitLogger(logFilePath string, options *Options) error {
+	if options == nil {
+		options = &Options{}
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	level, _ := options.getLevel()
+	encoderConfig := zapcore.EncoderConfig{
+		TimeKey:        "ts",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "",
+		FunctionKey:    zapcore.OmitKey,
+		MessageKey:     "msg",
+		StacktraceKey:  "",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeDuration: zapcore.MillisDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	var encoder zapcore.Encoder
+	if options.getEncoding() == EncodingConsole {
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	} else {
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	}
+
+	// The log file is opened by lumberjack on the first write, so check it can be opened now
+	file, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_ = file.Close()
+	newLogFile := &lumberjack.Logger{
+		Filename:   logFilePath,
+		MaxSize:    defaultMaxSizeMb,
+		MaxBackups: defaultMaxBackups,
+		MaxAge:     options.MaxAgeDays,
+		LocalTime:  true,
+		Compress:   options.Compress,
+	}
+	if options.MaxSizeMb > 0 {
+		newLogFile.MaxSize = options.MaxSizeMb
+	}
+	if options.MaxBackups > 0 {
+		newLogFile.MaxBackups = options.MaxBackups
 	}
+
+	// The redacting core is below the sampler, so that the sampler decides which entries are written
+	var core zapcore.Core = redactingCore{zapcore.NewCore(encoder, zapcore.AddSync(newLogFile), level)}
+	if !options.DisableSampling {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, samplingFirst, samplingThereafter)
+	}
+	newLogger := zap.New(core, zap.ErrorOutput(zapcore.Lock(os.Stderr)))
+
+	mu.Lock()
+	defer mu.Unlock()
+	_ = closeLogger()
+	logger = newLogger
+	logFile = newLogFile
+	return nil
+}
+
+func Info(msg string, fields ...zap.Field) {
+	write(zapcore.InfoLevel, msg, fields)
+}
+
+func Error(msg string, fields ...zap.Field) {
+	write(zapcore.ErrorLevel, msg, fields)
+}
+
+func Fatal(msg string, fields ...zap.Field) {
+	write(zapcore.FatalLevel, msg, fields)
+}
+
+func Debug(msg string, fields ...zap.Field) {
+	write(zapcore.DebugLevel, msg, fields)
 }
 
 func Warn(msg string, fields ...zap.Field) {
-	if logger != nil {
-		logger.Warn(msg, fields...)
+	write(zapcore.WarnLevel, msg, fields)
+}
+
+func write(level zapcore.Level, msg string, fields []zap.Field) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if logger == nil {
+		return
+	}
+	if checkedEntry := logger.Check(level, msg); checkedEntry != nil {
+		checkedEntry.Write(fields...)
 	}
 }
 
 func Sync() error {
+	mu.RLock()
+	defer mu.RUnlock()
 	if logger != nil {
 		return logger.Sync()
 	}
 	return nil
 }
+
+// Close flushes the logger, closes the log file and disables the logger,
+// the log functions do nothing until InitLogger is called again.
+// It is safe to call while other goroutines are logging.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	return closeLogger()
+}
+
+// closeLogger is Close with mu held.
+func closeLogger() error {
+	if logger == nil {
+		return nil
+	}
+	err := logger.Sync()
+	logger = nil
+	if closeErr := logFile.Close(); err == nil {
+		err = closeErr
+	}
+	logFile = nil
+	return err
+}
@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestInitLogger(t *testing.T) {
+	logFilePath := filepath.Join(t.TempDir(), "bark-tray.log")
+	assert.Nil(t, InitLogger(logFilePath, &Options{Level: "warn", Encoding: EncodingConsole}))
+	defer func() { _ = Close() }()
+	Info("hidden")
+	Warn("Invalid device", zap.String("device", "iPhone"))
+	assert.Nil(t, Close())
+
+	logBytes, err := os.ReadFile(logFilePath)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(logBytes)), "\n")
+	assert.Len(t, lines, 1)
+	assert.Regexp(t, regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}(Z|[+-]\d{4})\twarn\tInvalid device\t\{"device": "iPhone"\}$`), lines[0])
+}
+
+func TestLogRotation(t *testing.T) {
+	dir := t.TempDir()
+	logFilePath := filepath.Join(dir, "bark-tray.log")
+	assert.Nil(t, InitLogger(logFilePath, &Options{DisableSampling: true, MaxSizeMb: 1, MaxBackups: 1}))
+	defer func() { _ = Close() }()
+	line := strings.Repeat("x", 1024)
+	for i := 0; i < 2048; i++ {
+		Info(line)
+	}
+	assert.Nil(t, Close())
+
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 2)
+	info, err := os.Stat(logFilePath)
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(1024*1024))
+}
+
+func TestLogSampling(t *testing.T) {
+	countLines := func(options *Options) int {
+		logFilePath := filepath.Join(t.TempDir(), "bark-tray.log")
+		assert.Nil(t, InitLogger(logFilePath, options))
+		defer func() { _ = Close() }()
+		for i := 0; i < 1000; i++ {
+			Info("Clipboard is empty")
+		}
+		assert.Nil(t, Close())
+		logBytes, err := os.ReadFile(logFilePath)
+		assert.Nil(t, err)
+		return strings.Count(string(logBytes), "\n")
+	}
+	// The first 100 and every 100th after that, unless the second changes in between
+	assert.Less(t, countLines(&Options{}), 200)
+	assert.Equal(t, 1000, countLines(&Options{DisableSampling: true}))
+}
+
+func TestInitLoggerWhileLogging(t *testing.T) {
+	dir := t.TempDir()
+	defer func() { _ = Close() }()
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					Info("Successfully sent", zap.String("device", "iPhone"))
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		assert.Nil(t, InitLogger(filepath.Join(dir, fmt.Sprintf("bark-tray-%d.log", i%2)), nil))
+		if i%5 == 0 {
+			assert.Nil(t, Close())
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.Nil(t, (&Options{}).Validate())
+	assert.Nil(t, (&Options{Level: "debug", Encoding: EncodingJson}).Validate())
+	assert.NotNil(t, (&Options{Level: "fatal"}).Validate())
+	assert.NotNil(t, (&Options{Level: "verbose"}).Validate())
+	assert.NotNil(t, (&Options{Encoding: "text"}).Validate())
+	assert.NotNil(t, (&Options{MaxSizeMb: -1}).Validate())
+}
@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/sensitive"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"strings"
+	"sync/atomic"
+)
+
+// Redaction is how the message bodies and the device keys are written to the log.
+type Redaction string
+
+const (
+	// RedactionNone writes them as they are.
+	RedactionNone Redaction = "none"
+	// RedactionLength writes only their lengths, e.g. "[42 characters]".
+	RedactionLength Redaction = "length"
+	// RedactionHash writes the first 12 hex digits of their SHA-256 hashes,
+	// so that the same content can be recognized without being revealed.
+	RedactionHash Redaction = "hash"
+	// RedactionTruncate writes their first few characters and their lengths.
+	RedactionTruncate Redaction = "truncate"
+)
+
+// DefaultRedaction is the Redaction used if it is not configured.
+const DefaultRedaction = RedactionLength
+
+const (
+	bodyTruncateLength = 10
+	keyTruncateLength  = 4
+	hashLength         = 12
+)
+
+func (r Redaction) IsValid() bool {
+	switch r {
+	case RedactionNone, RedactionLength, RedactionHash, RedactionTruncate:
+		return true
+	}
+	return false
+}
+
+var redaction atomic.Value
+
+// SetRedaction sets how Body and Key redact their arguments, an empty redaction means DefaultRedaction.
+func SetRedaction(r Redaction) error {
+	if r == "" {
+		r = DefaultRedaction
+	}
+	if !r.IsValid() {
+		return fmt.Errorf("unsupported log redaction '%s', must be one of none, length, hash and truncate", r)
+	}
+	redaction.Store(r)
+	return nil
+}
+
+func getRedaction() Redaction {
+	if r, ok := redaction.Load().(Redaction); ok {
+		return r
+	}
+	return DefaultRedaction
+}
+
+// Body returns the message body to be written to the log. A body containing
+// a secret, such as a JWT or a credit card number, is always reduced to its length.
+func Body(body string) string {
+	if kinds := sensitive.Kinds(body); len(kinds) > 0 {
+		kindNames := make([]string, len(kinds))
+		for i, kind := range kinds {
+			kindNames[i] = string(kind)
+		}
+		return fmt.Sprintf("[%d characters, contains %s]", len([]rune(body)), strings.Join(kindNames, ", "))
+	}
+	return redact(body, bodyTruncateLength)
+}
+
+// BodyFields returns the log fields of a message body, i.e. the body redacted by Body and its size in bytes.
+func BodyFields(body string) []zap.Field {
+	return []zap.Field{zap.String("body", Body(body)), zap.Int("bytes", len(body))}
+}
+
+// Key returns the device key to be written to the log.
+func Key(key string) string {
+	return redact(key, keyTruncateLength)
+}
+
+func redact(text string, truncateLength int) string {
+	switch getRedaction() {
+	case RedactionNone:
+		return text
+	case RedactionHash:
+		hash := sha256.Sum256([]byte(text))
+		return "sha256:" + hex.EncodeToString(hash[:])[:hashLength]
+	case RedactionTruncate:
+		runes := []rune(text)
+		if len(runes) <= truncateLength {
+			return text
+		}
+		return fmt.Sprintf("%s... [%d characters]", string(runes[:truncateLength]), len(runes))
+	}
+	return fmt.Sprintf("[%d characters]", len([]rune(text)))
+}
+
+// redactingCore masks the secrets found by the sensitive package in all messages
+// and string fields, whatever the Redaction is, so that they never reach the log file.
+// Its Check does not call the Check of the wrapped core, so it must wrap the core
+// writing to the log file directly, and a sampler must wrap it rather than be wrapped.
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactingCore{c.Core.With(maskFields(fields))}
+}
+
+func (c redactingCore) Check(entry zapcore.Entry, checkedEntry *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checkedEntry.AddCore(entry, c)
+	}
+	return checkedEntry
+}
+
+func (c redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = sensitive.Mask(entry.Message)
+	return c.Core.Write(entry, maskFields(fields))
+}
+
+func maskFields(fields []zapcore.Field) []zapcore.Field {
+	maskedFields := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = sensitive.Mask(field.String)
+		case zapcore.ErrorType:
+			if err, ok := field.Interface.(error); ok {
+				field = zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: sensitive.Mask(err.Error())}
+			}
+		}
+		maskedFields[i] = field
+	}
+	return maskedFields
+}
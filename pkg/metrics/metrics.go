@@ -0,0 +1,280 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrorClass is the class of the error a push failed with.
+type ErrorClass string
+
+const (
+	// ClassTimeout means the Bark server did not respond in time.
+	ClassTimeout ErrorClass = "timeout"
+	// ClassNetwork means the Bark server could not be reached, e.g. the connection is refused.
+	ClassNetwork ErrorClass = "network"
+	// ClassRejected means the Bark server rejected the push request, e.g. the device key is invalid.
+	ClassRejected ErrorClass = "rejected"
+	// ClassRateLimited means the Bark server responded with 429 Too Many Requests.
+	ClassRateLimited ErrorClass = "rate_limited"
+	// ClassServer means the Bark server failed to handle the push request.
+	ClassServer ErrorClass = "server"
+	// ClassOther is any other error, e.g. the response is not a valid PushResponse.
+	ClassOther ErrorClass = "other"
+)
+
+// ErrorClasses is all the error classes in the order they are reported.
+var ErrorClasses = []ErrorClass{ClassTimeout, ClassNetwork, ClassRejected, ClassRateLimited, ClassServer, ClassOther}
+
+// Classify returns the class of err, which is not nil.
+func Classify(err error) ErrorClass {
+	var pushError *bark.PushError
+	if errors.As(err, &pushError) {
+		switch {
+		case pushError.Code == http.StatusTooManyRequests:
+			return ClassRateLimited
+		case pushError.Code >= 500:
+			return ClassServer
+		}
+		return ClassRejected
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ClassTimeout
+	}
+	var netError net.Error
+	if errors.As(err, &netError) {
+		if netError.Timeout() {
+			return ClassTimeout
+		}
+		return ClassNetwork
+	}
+	return ClassOther
+}
+
+// LatencyBuckets is the upper bounds of the buckets of the latency histograms.
+var LatencyBuckets = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// Histogram is the distribution of the push latencies over LatencyBuckets.
+type Histogram struct {
+	// Counts is the number of latencies in each bucket, the last one is for
+	// the latencies greater than the last bound of LatencyBuckets.
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+func newHistogram() Histogram {
+	return Histogram{Counts: make([]uint64, len(LatencyBuckets)+1)}
+}
+
+func (h *Histogram) observe(latency time.Duration) {
+	i := sort.Search(len(LatencyBuckets), func(i int) bool {
+		return latency <= LatencyBuckets[i]
+	})
+	h.Counts[i]++
+	h.Count++
+	h.Sum += latency
+	if latency > h.Max {
+		h.Max = latency
+	}
+}
+
+// Mean returns the mean latency, or 0 if there is no latency.
+func (h *Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns the upper bound of the bucket containing the q-quantile, e.g. 0.95,
+// or Max if it is smaller or the quantile is beyond the last bound. It returns 0 if there is no latency.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.Count)))
+	var count uint64
+	for i, bound := range LatencyBuckets {
+		count += h.Counts[i]
+		if count >= rank {
+			if bound < h.Max {
+				return bound
+			}
+			break
+		}
+	}
+	return h.Max
+}
+
+// DeviceStats is the statistics of the pushes to a device.
+type DeviceStats struct {
+	Device    string
+	Sent      uint64
+	Delivered uint64
+	// Failures is the number of failed pushes of each ErrorClass.
+	Failures map[ErrorClass]uint64
+	Latency  Histogram
+	// LastDeliveredAt is the time the Bark server accepted the last delivered push,
+	// taken from PushResponse.Timestamp, or the local time if the server does not report it.
+	LastDeliveredAt time.Time
+	// LastFailure is the error of the last failed push.
+	LastFailure string
+}
+
+// Failed returns the number of failed pushes.
+func (s *DeviceStats) Failed() uint64 {
+	return s.Sent - s.Delivered
+}
+
+// Metrics records the pushes to each device since it is created or reset.
+// It is safe for concurrent use.
+type Metrics struct {
+	mu        sync.Mutex
+	devices   map[string]*DeviceStats
+	startedAt time.Time
+	now       func() time.Time
+}
+
+// New creates empty metrics.
+func New() *Metrics {
+	m := &Metrics{now: time.Now}
+	m.Reset()
+	return m
+}
+
+// Record records a push to the device named device, which took latency and ended
+// with response or err, response is ignored if err is not nil.
+func (m *Metrics) Record(device string, latency time.Duration, response *bark.PushResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats, ok := m.devices[device]
+	if !ok {
+		stats = &DeviceStats{Device: device, Failures: make(map[ErrorClass]uint64), Latency: newHistogram()}
+		m.devices[device] = stats
+	}
+	stats.Sent++
+	stats.Latency.observe(latency)
+	if err != nil {
+		stats.Failures[Classify(err)]++
+		stats.LastFailure = err.Error()
+		return
+	}
+	stats.Delivered++
+	if response != nil && response.Timestamp > 0 {
+		stats.LastDeliveredAt = time.Unix(response.Timestamp, 0)
+	} else {
+		stats.LastDeliveredAt = m.now()
+	}
+}
+
+// StartedAt returns the time the metrics are created or reset.
+func (m *Metrics) StartedAt() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.startedAt
+}
+
+// Devices returns a copy of the statistics of each device, sorted by the device names.
+func (m *Metrics) Devices() []DeviceStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	devices := make([]DeviceStats, 0, len(m.devices))
+	for _, stats := range m.devices {
+		statsCopy := *stats
+		statsCopy.Failures = make(map[ErrorClass]uint64, len(stats.Failures))
+		for class, count := range stats.Failures {
+			statsCopy.Failures[class] = count
+		}
+		statsCopy.Latency.Counts = append([]uint64(nil), stats.Latency.Counts...)
+		devices = append(devices, statsCopy)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Device < devices[j].Device
+	})
+	return devices
+}
+
+// Reset removes all the recorded pushes.
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.devices = make(map[string]*DeviceStats)
+	m.startedAt = m.now()
+}
+
+// WritePrometheus writes the metrics to w in the Prometheus text exposition format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	devices := m.Devices()
+	var b strings.Builder
+
+	writeHeader(&b, "bark_tray_pushes_total", "counter", "Number of push requests sent to each device.")
+	for _, stats := range devices {
+		fmt.Fprintf(&b, "bark_tray_pushes_total{device=%s} %d\n", quoteLabel(stats.Device), stats.Sent)
+	}
+	writeHeader(&b, "bark_tray_push_failures_total", "counter", "Number of failed push requests by device and error class.")
+	for _, stats := range devices {
+		for _, class := range ErrorClasses {
+			if count := stats.Failures[class]; count > 0 {
+				fmt.Fprintf(&b, "bark_tray_push_failures_total{device=%s,class=%s} %d\n", quoteLabel(stats.Device), quoteLabel(string(class)), count)
+			}
+		}
+	}
+	writeHeader(&b, "bark_tray_push_duration_seconds", "histogram", "Latency of the push requests sent to each device.")
+	for _, stats := range devices {
+		device := quoteLabel(stats.Device)
+		var count uint64
+		for i, bound := range LatencyBuckets {
+			count += stats.Latency.Counts[i]
+			fmt.Fprintf(&b, "bark_tray_push_duration_seconds_bucket{device=%s,le=\"%s\"} %d\n", device, formatSeconds(bound), count)
+		}
+		fmt.Fprintf(&b, "bark_tray_push_duration_seconds_bucket{device=%s,le=\"+Inf\"} %d\n", device, stats.Latency.Count)
+		fmt.Fprintf(&b, "bark_tray_push_duration_seconds_sum{device=%s} %s\n", device, formatSeconds(stats.Latency.Sum))
+		fmt.Fprintf(&b, "bark_tray_push_duration_seconds_count{device=%s} %d\n", device, stats.Latency.Count)
+	}
+	writeHeader(&b, "bark_tray_last_delivery_timestamp_seconds", "gauge", "Time the Bark server accepted the last delivered push to each device.")
+	for _, stats := range devices {
+		if !stats.LastDeliveredAt.IsZero() {
+			fmt.Fprintf(&b, "bark_tray_last_delivery_timestamp_seconds{device=%s} %d\n", quoteLabel(stats.Device), stats.LastDeliveredAt.Unix())
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeHeader(b *strings.Builder, name string, metricType string, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel returns the label value quoted and escaped as required by the Prometheus text format.
+func quoteLabel(value string) string {
+	return `"` + labelReplacer.Replace(value) + `"`
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	assert.Equal(t, ClassRejected, Classify(&bark.PushError{Code: 400, Message: "failed to get device token"}))
+	assert.Equal(t, ClassRateLimited, Classify(&bark.PushError{Code: 429, Message: "Too Many Requests"}))
+	assert.Equal(t, ClassServer, Classify(fmt.Errorf("push: %w", &bark.PushError{Code: 502, Message: "Bad Gateway"})))
+	assert.Equal(t, ClassTimeout, Classify(context.DeadlineExceeded))
+	assert.Equal(t, ClassTimeout, Classify(&net.DNSError{Err: "i/o timeout", IsTimeout: true}))
+	assert.Equal(t, ClassNetwork, Classify(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.Equal(t, ClassOther, Classify(errors.New("invalid character '<' looking for beginning of value")))
+}
+
+func TestRecord(t *testing.T) {
+	m := New()
+	m.Record("iPhone", 80*time.Millisecond, &bark.PushResponse{Code: 200, Timestamp: 1700000000}, nil)
+	m.Record("iPhone", 300*time.Millisecond, &bark.PushResponse{Code: 200, Timestamp: 1700000060}, nil)
+	m.Record("iPhone", 40*time.Second, nil, context.DeadlineExceeded)
+	m.Record("iPad", 120*time.Millisecond, nil, &bark.PushError{Code: 400, Message: "invalid key"})
+
+	devices := m.Devices()
+	assert.Len(t, devices, 2)
+	assert.Equal(t, "iPad", devices[0].Device)
+	assert.Equal(t, uint64(1), devices[0].Failed())
+	assert.Equal(t, uint64(1), devices[0].Failures[ClassRejected])
+	assert.Equal(t, "invalid key", devices[0].LastFailure)
+	assert.True(t, devices[0].LastDeliveredAt.IsZero())
+
+	iPhone := devices[1]
+	assert.Equal(t, uint64(3), iPhone.Sent)
+	assert.Equal(t, uint64(2), iPhone.Delivered)
+	assert.Equal(t, uint64(1), iPhone.Failures[ClassTimeout])
+	assert.Equal(t, time.Unix(1700000060, 0), iPhone.LastDeliveredAt)
+	assert.Equal(t, uint64(3), iPhone.Latency.Count)
+	assert.Equal(t, uint64(1), iPhone.Latency.Counts[0])
+	assert.Equal(t, uint64(1), iPhone.Latency.Counts[len(LatencyBuckets)])
+	assert.Equal(t, 40*time.Second, iPhone.Latency.Max)
+	assert.Equal(t, 100*time.Millisecond, iPhone.Latency.Quantile(0.3))
+	assert.Equal(t, 500*time.Millisecond, iPhone.Latency.Quantile(0.5))
+	assert.Equal(t, 40*time.Second, iPhone.Latency.Quantile(0.95))
+
+	m.Reset()
+	assert.Empty(t, m.Devices())
+}
+
+func TestWritePrometheus(t *testing.T) {
+	m := New()
+	m.Record(`My "iPhone"`, 200*time.Millisecond, &bark.PushResponse{Code: 200, Timestamp: 1700000000}, nil)
+	m.Record(`My "iPhone"`, time.Second, nil, &bark.PushError{Code: 503, Message: "Service Unavailable"})
+
+	var b strings.Builder
+	assert.Nil(t, m.WritePrometheus(&b))
+	text := b.String()
+	assert.Contains(t, text, "# TYPE bark_tray_pushes_total counter\n")
+	assert.Contains(t, text, `bark_tray_pushes_total{device="My \"iPhone\""} 2`+"\n")
+	assert.Contains(t, text, `bark_tray_push_failures_total{device="My \"iPhone\"",class="server"} 1`+"\n")
+	assert.Contains(t, text, `bark_tray_push_duration_seconds_bucket{device="My \"iPhone\"",le="0.1"} 0`+"\n")
+	assert.Contains(t, text, `bark_tray_push_duration_seconds_bucket{device="My \"iPhone\"",le="0.25"} 1`+"\n")
+	assert.Contains(t, text, `bark_tray_push_duration_seconds_bucket{device="My \"iPhone\"",le="1"} 2`+"\n")
+	assert.Contains(t, text, `bark_tray_push_duration_seconds_bucket{device="My \"iPhone\"",le="+Inf"} 2`+"\n")
+	assert.Contains(t, text, `bark_tray_push_duration_seconds_sum{device="My \"iPhone\""} 1.2`+"\n")
+	assert.Contains(t, text, `bark_tray_last_delivery_timestamp_seconds{device="My \"iPhone\""} 1700000000`+"\n")
+}
@@ -0,0 +1,304 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// initialRetryDelay is the delay before the first automatic retry,
+	// it doubles after each failed attempt until it reaches maxRetryDelay.
+	initialRetryDelay = 30 * time.Second
+	maxRetryDelay     = time.Hour
+	// maxAttempts is the maximum number of automatic retries,
+	// the entry is kept for manual retry after that.
+	maxAttempts = 10
+	// checkInterval is the interval to check for entries to retry.
+	checkInterval = 10 * time.Second
+)
+
+// Entry is a push request that failed to be sent.
+type Entry struct {
+	Id          string            `json:"id"`
+	DeviceName  string            `json:"deviceName"`
+	PushRequest *bark.PushRequest `json:"pushRequest"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	Attempts    int               `json:"attempts"`
+	// NextRetryAt is the time of the next automatic retry,
+	// it is zero if the entry will not be retried automatically.
+	NextRetryAt time.Time `json:"nextRetryAt"`
+	LastError   string    `json:"lastError"`
+}
+
+// PushFunc sends the push request of entry to its device.
+type PushFunc func(entry *Entry) error
+
+// Outbox stores the failed push requests in a file and retries them
+// with exponential backoff in the background.
+type Outbox struct {
+	mu       sync.Mutex
+	filePath string
+	entries  []*Entry
+	// retrying is the ids of entries being retried,
+	// to prevent an entry from being sent twice at the same time.
+	retrying map[string]bool
+	lastId   int64
+	push     PushFunc
+	onChange func()
+	onRetry  func(entry *Entry, err error)
+	now      func() time.Time
+}
+
+// Open loads the outbox from filePath, the file will be created
+// when the first entry is added if it does not exist.
+func Open(filePath string, push PushFunc) (*Outbox, error) {
+	o := &Outbox{
+		filePath: filePath,
+		retrying: make(map[string]bool),
+		push:     push,
+		now:      time.Now,
+	}
+	outboxFileBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return o, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(outboxFileBytes, &o.entries)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// SetOnChange sets the function called after the entries are changed.
+func (o *Outbox) SetOnChange(onChange func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.onChange = onChange
+}
+
+// SetOnRetry sets the function called after an entry is retried,
+// err is nil if the entry is sent successfully.
+func (o *Outbox) SetOnRetry(onRetry func(entry *Entry, err error)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.onRetry = onRetry
+}
+
+// Add adds a push request failed with pushErr to the outbox.
+func (o *Outbox) Add(deviceName string, pushRequest *bark.PushRequest, pushErr error) error {
+	o.mu.Lock()
+	now := o.now()
+	id := now.UnixNano()
+	if id <= o.lastId {
+		id = o.lastId + 1
+	}
+	o.lastId = id
+	o.entries = append(o.entries, &Entry{
+		Id:          fmt.Sprintf("%d", id),
+		DeviceName:  deviceName,
+		PushRequest: pushRequest,
+		CreatedAt:   now,
+		NextRetryAt: now.Add(initialRetryDelay),
+		LastError:   pushErr.Error(),
+	})
+	err := o.save()
+	o.mu.Unlock()
+	o.changed()
+	return err
+}
+
+// Entries returns a copy of all entries in the order they were added.
+func (o *Outbox) Entries() []Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entries := make([]Entry, len(o.entries))
+	for i, entry := range o.entries {
+		entries[i] = *entry
+	}
+	return entries
+}
+
+// Len returns the number of entries.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// Retry sends the entry with id immediately, the entry is removed if it is sent successfully.
+func (o *Outbox) Retry(id string) error {
+	o.mu.Lock()
+	entry := o.find(id)
+	if entry == nil {
+		o.mu.Unlock()
+		return fmt.Errorf("entry %s not found", id)
+	}
+	if o.retrying[id] {
+		o.mu.Unlock()
+		return fmt.Errorf("entry %s is being retried", id)
+	}
+	o.retrying[id] = true
+	retryEntry := *entry
+	o.mu.Unlock()
+	return o.retry(&retryEntry)
+}
+
+// RetryAll sends all entries immediately.
+func (o *Outbox) RetryAll() {
+	for _, entry := range o.Entries() {
+		_ = o.Retry(entry.Id)
+	}
+}
+
+// Discard removes the entry with id without sending it.
+func (o *Outbox) Discard(id string) error {
+	o.mu.Lock()
+	if o.find(id) == nil {
+		o.mu.Unlock()
+		return fmt.Errorf("entry %s not found", id)
+	}
+	o.remove(id)
+	err := o.save()
+	o.mu.Unlock()
+	o.changed()
+	return err
+}
+
+// DiscardAll removes all entries without sending them.
+func (o *Outbox) DiscardAll() error {
+	o.mu.Lock()
+	o.entries = nil
+	err := o.save()
+	o.mu.Unlock()
+	o.changed()
+	return err
+}
+
+// Run retries the entries whose NextRetryAt has come until stop is closed.
+func (o *Outbox) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			o.retryDue()
+		}
+	}
+}
+
+// retryDue retries all entries whose NextRetryAt has come.
+func (o *Outbox) retryDue() {
+	o.mu.Lock()
+	now := o.now()
+	var dueEntries []*Entry
+	for _, entry := range o.entries {
+		if !entry.NextRetryAt.IsZero() && !entry.NextRetryAt.After(now) && !o.retrying[entry.Id] {
+			o.retrying[entry.Id] = true
+			retryEntry := *entry
+			dueEntries = append(dueEntries, &retryEntry)
+		}
+	}
+	o.mu.Unlock()
+	for _, entry := range dueEntries {
+		_ = o.retry(entry)
+	}
+}
+
+// retry sends entry, which must have been marked as retrying,
+// and updates the outbox according to the result.
+func (o *Outbox) retry(entry *Entry) error {
+	pushErr := o.push(entry)
+
+	o.mu.Lock()
+	delete(o.retrying, entry.Id)
+	storedEntry := o.find(entry.Id)
+	if storedEntry == nil {
+		// The entry has been discarded while retrying
+		o.mu.Unlock()
+		return pushErr
+	}
+	if pushErr == nil {
+		o.remove(entry.Id)
+	} else {
+		storedEntry.Attempts++
+		storedEntry.LastError = pushErr.Error()
+		if storedEntry.Attempts >= maxAttempts || !bark.IsRetryable(pushErr) {
+			storedEntry.NextRetryAt = time.Time{}
+		} else {
+			storedEntry.NextRetryAt = o.now().Add(retryDelay(storedEntry.Attempts))
+		}
+		*entry = *storedEntry
+	}
+	err := o.save()
+	onRetry := o.onRetry
+	o.mu.Unlock()
+
+	if onRetry != nil {
+		onRetry(entry, pushErr)
+	}
+	o.changed()
+	if pushErr != nil {
+		return pushErr
+	}
+	return err
+}
+
+// retryDelay returns the delay before the next retry after attempts failed attempts.
+func retryDelay(attempts int) time.Duration {
+	delay := initialRetryDelay
+	for i := 0; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+func (o *Outbox) find(id string) *Entry {
+	for _, entry := range o.entries {
+		if entry.Id == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (o *Outbox) remove(id string) {
+	newEntries := make([]*Entry, 0, len(o.entries))
+	for _, entry := range o.entries {
+		if entry.Id != id {
+			newEntries = append(newEntries, entry)
+		}
+	}
+	o.entries = newEntries
+}
+
+func (o *Outbox) changed() {
+	o.mu.Lock()
+	onChange := o.onChange
+	o.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// save writes the entries to the outbox file, the file may contain
+// sensitive clipboard content, so it is only readable by the current user.
+func (o *Outbox) save() error {
+	outboxFileBytes, err := json.MarshalIndent(o.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(o.filePath, outboxFileBytes, 0600)
+}
@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"errors"
+	"github.com/LGiki/bark-tray/pkg/bark"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOutboxPersistence(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "outbox.json")
+	o, err := Open(filePath, func(entry *Entry) error { return nil })
+	assert.Nil(t, err)
+	assert.Nil(t, o.Add("MY_PHONE", &bark.PushRequest{Body: "hello"}, errors.New("timeout")))
+	assert.Nil(t, o.Add("MY_IPAD", &bark.PushRequest{Body: "world"}, errors.New("timeout")))
+
+	reopened, err := Open(filePath, func(entry *Entry) error { return nil })
+	assert.Nil(t, err)
+	entries := reopened.Entries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "MY_PHONE", entries[0].DeviceName)
+	assert.Equal(t, "hello", entries[0].PushRequest.Body)
+	assert.Equal(t, "timeout", entries[0].LastError)
+	assert.NotEqual(t, entries[0].Id, entries[1].Id)
+
+	assert.Nil(t, reopened.Discard(entries[0].Id))
+	assert.Nil(t, reopened.Retry(entries[1].Id))
+	assert.Equal(t, 0, reopened.Len())
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	pushErr := errors.New("timeout")
+	var pushed int
+	o, err := Open(filepath.Join(t.TempDir(), "outbox.json"), func(entry *Entry) error {
+		pushed++
+		return pushErr
+	})
+	assert.Nil(t, err)
+	o.now = func() time.Time { return now }
+	assert.Nil(t, o.Add("MY_PHONE", &bark.PushRequest{Body: "hello"}, pushErr))
+
+	// Not due yet
+	o.retryDue()
+	assert.Equal(t, 0, pushed)
+
+	now = now.Add(initialRetryDelay)
+	o.retryDue()
+	assert.Equal(t, 1, pushed)
+	entry := o.Entries()[0]
+	assert.Equal(t, 1, entry.Attempts)
+	assert.Equal(t, now.Add(2*initialRetryDelay), entry.NextRetryAt)
+
+	// Not retryable errors stop the automatic retry
+	pushErr = &bark.PushError{Code: 400, Message: "failed to get device token"}
+	now = entry.NextRetryAt
+	o.retryDue()
+	assert.Equal(t, 2, pushed)
+	entry = o.Entries()[0]
+	assert.True(t, entry.NextRetryAt.IsZero())
+	assert.Equal(t, "failed to get device token", entry.LastError)
+
+	now = now.Add(maxRetryDelay)
+	o.retryDue()
+	assert.Equal(t, 2, pushed)
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 60*time.Second, retryDelay(1))
+	assert.Equal(t, 120*time.Second, retryDelay(2))
+	assert.Equal(t, maxRetryDelay, retryDelay(20))
+}
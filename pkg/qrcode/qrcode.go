@@ -0,0 +1,30 @@
+package qrcode
+
+import (
+	"bytes"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Decode returns the text of the QR code in imageData, which is a PNG or JPEG image.
+func Decode(imageData []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return "", err
+	}
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", err
+	}
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+	result, err := qrcode.NewQRCodeReader().Decode(bitmap, hints)
+	if err != nil {
+		return "", err
+	}
+	return result.GetText(), nil
+}
@@ -0,0 +1,36 @@
+package qrcode
+
+import (
+	"bytes"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func encodePng(t *testing.T, img image.Image) []byte {
+	var buffer bytes.Buffer
+	assert.Nil(t, png.Encode(&buffer, img))
+	return buffer.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	text := "https://api.day.app/abcdefghijklmnopqrstuv/example"
+	qrCode, err := qrcode.NewQRCodeWriter().Encode(text, gozxing.BarcodeFormat_QR_CODE, 200, 200, nil)
+	assert.Nil(t, err)
+	decodedText, err := Decode(encodePng(t, qrCode))
+	assert.Nil(t, err)
+	assert.Equal(t, text, decodedText)
+
+	blank := image.NewGray(image.Rect(0, 0, 100, 100))
+	for i := range blank.Pix {
+		blank.Pix[i] = 0xff
+	}
+	_, err = Decode(encodePng(t, blank))
+	assert.NotNil(t, err)
+
+	_, err = Decode([]byte("not an image"))
+	assert.NotNil(t, err)
+}
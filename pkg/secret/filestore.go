@@ -0,0 +1,197 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"golang.org/x/crypto/pbkdf2"
+	"os"
+	"sync"
+)
+
+const (
+	fileStoreVersion = 1
+	// defaultIterations is the number of PBKDF2-HMAC-SHA256 iterations recommended by OWASP.
+	defaultIterations = 600000
+	saltSize          = 16
+	keySize           = 32
+)
+
+// ErrWrongPassphrase is returned if the encrypted file cannot be decrypted with the passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase or the secret file is corrupted")
+
+// encryptedFile is the content of the file of FileStore, the secrets are encrypted
+// with AES-256-GCM by the key derived from the passphrase with PBKDF2-HMAC-SHA256.
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// FileStore is a Store that keeps the secrets in a file encrypted by a passphrase,
+// it is used where the OS keyring is not available.
+type FileStore struct {
+	mu         sync.Mutex
+	filePath   string
+	passphrase string
+	// iterations is the number of PBKDF2 iterations used when the file is written.
+	iterations int
+	// key is the key derived from the passphrase with keySalt and keyIterations.
+	key           []byte
+	keySalt       []byte
+	keyIterations int
+}
+
+// NewFileStore returns a FileStore of the file at filePath, which is created when the first secret is set.
+func NewFileStore(filePath string, passphrase string) (*FileStore, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is empty")
+	}
+	return &FileStore{
+		filePath:   filePath,
+		passphrase: passphrase,
+		iterations: defaultIterations,
+	}, nil
+}
+
+func (s *FileStore) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (s *FileStore) Set(name string, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.read()
+	if err != nil {
+		return err
+	}
+	secrets[name] = secret
+	return s.write(secrets)
+}
+
+func (s *FileStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return ErrNotFound
+	}
+	delete(secrets, name)
+	return s.write(secrets)
+}
+
+// Verify checks that the file can be decrypted with the passphrase, it returns
+// ErrWrongPassphrase if it cannot, and nil if the file does not exist yet.
+func (s *FileStore) Verify() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.read()
+	return err
+}
+
+// read decrypts the secrets in the file, which is empty if the file does not exist.
+func (s *FileStore) read() (map[string]string, error) {
+	fileBytes, err := os.ReadFile(s.filePath)
+	if os.IsNotExist(err) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file encryptedFile
+	if err = json.Unmarshal(fileBytes, &file); err != nil {
+		return nil, fmt.Errorf("invalid secret file: %s", err.Error())
+	}
+	if file.Version != fileStoreVersion {
+		return nil, fmt.Errorf("unsupported secret file version %d", file.Version)
+	}
+	aead, err := s.aead(file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	secrets := make(map[string]string)
+	if err = json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("invalid secret file: %s", err.Error())
+	}
+	return secrets, nil
+}
+
+// write encrypts secrets with a new nonce, and writes them to the file atomically.
+// The salt of the cached key is kept, a new salt is only generated for a new key.
+func (s *FileStore) write(secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	file := encryptedFile{
+		Version:    fileStoreVersion,
+		Iterations: s.iterations,
+		Salt:       s.keySalt,
+	}
+	if s.key == nil || s.keyIterations != s.iterations {
+		file.Salt = make([]byte, saltSize)
+		if _, err = rand.Read(file.Salt); err != nil {
+			return err
+		}
+	}
+	aead, err := s.aead(file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+	fileBytes, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(s.filePath, fileBytes, 0600)
+}
+
+// aead returns the AEAD of the key derived from the passphrase with salt and iterations,
+// the key is cached since deriving it is slow on purpose.
+func (s *FileStore) aead(salt []byte, iterations int) (cipher.AEAD, error) {
+	if iterations <= 0 || len(salt) == 0 {
+		return nil, errors.New("invalid secret file: missing key derivation parameters")
+	}
+	if s.key == nil || s.keyIterations != iterations || !bytes.Equal(s.keySalt, salt) {
+		s.key = pbkdf2.Key([]byte(s.passphrase), salt, iterations, keySize, sha256.New)
+		s.keySalt = append([]byte(nil), salt...)
+		s.keyIterations = iterations
+	}
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
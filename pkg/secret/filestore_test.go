@@ -0,0 +1,71 @@
+package secret
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestFileStore(t *testing.T, filePath string, passphrase string) *FileStore {
+	store, err := NewFileStore(filePath, passphrase)
+	assert.Nil(t, err)
+	// Fast enough for the tests
+	store.iterations = 1000
+	return store
+}
+
+func TestFileStore(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "secrets.enc")
+	store := newTestFileStore(t, filePath, "correct horse")
+	_, err := store.Get("MY_PHONE")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, store.Set("MY_PHONE", "abcdefghijklmnopqrstuv"))
+	assert.Nil(t, store.Set("MY_IPAD", "vutsrqponmlkjihgfedcba"))
+
+	fileInfo, err := os.Stat(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+	fileBytes, err := os.ReadFile(filePath)
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(string(fileBytes), "abcdefghijklmnopqrstuv"))
+
+	reopened := newTestFileStore(t, filePath, "correct horse")
+	secret, err := reopened.Get("MY_PHONE")
+	assert.Nil(t, err)
+	assert.Equal(t, "abcdefghijklmnopqrstuv", secret)
+	assert.Nil(t, reopened.Delete("MY_PHONE"))
+	_, err = reopened.Get("MY_PHONE")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, reopened.Delete("MY_PHONE"), ErrNotFound)
+	secret, err = reopened.Get("MY_IPAD")
+	assert.Nil(t, err)
+	assert.Equal(t, "vutsrqponmlkjihgfedcba", secret)
+
+	wrongPassphrase := newTestFileStore(t, filePath, "wrong")
+	_, err = wrongPassphrase.Get("MY_IPAD")
+	assert.ErrorIs(t, err, ErrWrongPassphrase)
+
+	_, err = NewFileStore(filePath, "")
+	assert.NotNil(t, err)
+}
+
+func TestFileStoreKeyCache(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "secrets.enc")
+	store := newTestFileStore(t, filePath, "correct horse")
+	assert.Nil(t, store.Set("MY_PHONE", "abcdefghijklmnopqrstuv"))
+	key := store.key
+	salt := store.keySalt
+
+	// The key is derived once, and the salt is kept when the file is written again
+	assert.Nil(t, store.Set("MY_IPAD", "vutsrqponmlkjihgfedcba"))
+	_, err := store.Get("MY_PHONE")
+	assert.Nil(t, err)
+	assert.Same(t, &key[0], &store.key[0])
+	assert.Equal(t, salt, store.keySalt)
+
+	assert.Nil(t, store.Verify())
+	assert.ErrorIs(t, newTestFileStore(t, filePath, "wrong").Verify(), ErrWrongPassphrase)
+	assert.Nil(t, newTestFileStore(t, filepath.Join(t.TempDir(), "missing.enc"), "wrong").Verify())
+}
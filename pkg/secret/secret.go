@@ -0,0 +1,92 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The prefixes of the secret references.
+const (
+	// KeyringPrefix references a secret in the Store, e.g. "keyring:MY_PHONE".
+	KeyringPrefix = "keyring:"
+	// EnvPrefix references an environment variable, e.g. "env:BARK_KEY".
+	EnvPrefix = "env:"
+	// FilePrefix references the content of a file, e.g. "file:/run/secrets/bark_key".
+	FilePrefix = "file:"
+)
+
+// ErrNotFound is returned by Store.Get if the secret does not exist.
+var ErrNotFound = errors.New("secret not found")
+
+// Store stores the secrets referenced by "keyring:NAME".
+type Store interface {
+	Get(name string) (string, error)
+	Set(name string, secret string) error
+	Delete(name string) error
+}
+
+// IsReference reports whether value references a secret instead of being the secret itself.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, KeyringPrefix) ||
+		strings.HasPrefix(value, EnvPrefix) ||
+		strings.HasPrefix(value, FilePrefix)
+}
+
+// Resolver resolves the secret references.
+type Resolver struct {
+	// Store is where the "keyring:" references are resolved from, they cannot be resolved if it is nil.
+	Store Store
+	// BaseDir is the directory the relative paths of the "file:" references are relative to.
+	BaseDir string
+}
+
+// Retry lets the Store try again to open the encrypted file if it failed before,
+// e.g. the passphrase was wrong or not entered.
+func (r *Resolver) Retry() {
+	if store, ok := r.Store.(retrier); ok {
+		store.Retry()
+	}
+}
+
+// Resolve returns the secret referenced by value, or value itself if it is not a reference.
+func (r *Resolver) Resolve(value string) (string, error) {
+	var secret string
+	switch {
+	case strings.HasPrefix(value, KeyringPrefix):
+		name := strings.TrimPrefix(value, KeyringPrefix)
+		if r.Store == nil {
+			return "", fmt.Errorf("failed to read secret '%s': no secret store", name)
+		}
+		var err error
+		secret, err = r.Store.Get(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret '%s': %s", name, err.Error())
+		}
+	case strings.HasPrefix(value, EnvPrefix):
+		name := strings.TrimPrefix(value, EnvPrefix)
+		secret = os.Getenv(name)
+		if secret == "" {
+			return "", fmt.Errorf("environment variable '%s' is not set", name)
+		}
+	case strings.HasPrefix(value, FilePrefix):
+		filePath := strings.TrimPrefix(value, FilePrefix)
+		if !filepath.IsAbs(filePath) && r.BaseDir != "" {
+			filePath = filepath.Join(r.BaseDir, filePath)
+		}
+		secretBytes, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %s", err.Error())
+		}
+		// The trailing newline added by the editors is not part of the secret
+		secret = strings.TrimSpace(string(secretBytes))
+	default:
+		return value, nil
+	}
+	if secret == "" {
+		return "", fmt.Errorf("secret '%s' is empty", value)
+	}
+	return secret, nil
+}
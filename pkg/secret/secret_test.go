@@ -0,0 +1,66 @@
+package secret
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// memoryStore is a Store in memory.
+type memoryStore map[string]string
+
+func (s memoryStore) Get(name string) (string, error) {
+	secret, ok := s[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (s memoryStore) Set(name string, secret string) error {
+	s[name] = secret
+	return nil
+}
+
+func (s memoryStore) Delete(name string) error {
+	delete(s, name)
+	return nil
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "key.txt"), []byte("file-key\n"), 0600))
+	t.Setenv("BARK_TEST_KEY", "env-key")
+	t.Setenv("BARK_TEST_EMPTY", "")
+	resolver := &Resolver{Store: memoryStore{"MY_PHONE": "keyring-key"}, BaseDir: dir}
+
+	testCases := []struct {
+		value    string
+		expected string
+		isError  bool
+	}{
+		{value: "plain-key", expected: "plain-key"},
+		{value: "keyring:MY_PHONE", expected: "keyring-key"},
+		{value: "keyring:MY_IPAD", isError: true},
+		{value: "env:BARK_TEST_KEY", expected: "env-key"},
+		{value: "env:BARK_TEST_EMPTY", isError: true},
+		{value: "file:key.txt", expected: "file-key"},
+		{value: "file:" + filepath.Join(dir, "key.txt"), expected: "file-key"},
+		{value: "file:missing.txt", isError: true},
+	}
+	for _, testCase := range testCases {
+		secret, err := resolver.Resolve(testCase.value)
+		if testCase.isError {
+			assert.NotNil(t, err, testCase.value)
+		} else {
+			assert.Nil(t, err, testCase.value)
+			assert.Equal(t, testCase.expected, secret, testCase.value)
+		}
+	}
+
+	_, err := (&Resolver{}).Resolve("keyring:MY_PHONE")
+	assert.NotNil(t, err)
+	assert.True(t, IsReference("keyring:MY_PHONE"))
+	assert.False(t, IsReference("abcdefghijklmnopqrstuv"))
+}
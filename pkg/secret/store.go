@@ -0,0 +1,239 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zalando/go-keyring"
+	"path/filepath"
+	"sync"
+)
+
+// The backends of the secret store.
+const (
+	// BackendAuto uses the OS keyring, and falls back to the encrypted file if it is not available.
+	BackendAuto    = "auto"
+	BackendKeyring = "keyring"
+	BackendFile    = "file"
+)
+
+const (
+	// keyringService is the service name of the secrets in the OS keyring.
+	keyringService  = "bark-tray"
+	defaultFileName = "secrets.enc"
+	// PassphraseEnv is the environment variable of the passphrase of the encrypted file.
+	PassphraseEnv = "BARK_TRAY_PASSPHRASE"
+)
+
+// Options is the setting of the store of the "keyring:" references.
+type Options struct {
+	// Backend is one of BackendAuto, BackendKeyring and BackendFile, defaults to BackendAuto.
+	Backend string `json:"backend"`
+	// File is the path of the encrypted file, a relative path is relative to the data directory.
+	// Defaults to "secrets.enc".
+	File string `json:"file"`
+}
+
+// Validate checks whether the options are valid.
+func (o *Options) Validate() error {
+	switch o.GetBackend() {
+	case BackendAuto, BackendKeyring, BackendFile:
+		return nil
+	}
+	return fmt.Errorf("unsupported backend '%s', must be one of auto, keyring and file", o.Backend)
+}
+
+// GetBackend returns Options.Backend, or BackendAuto if it is not set.
+func (o *Options) GetBackend() string {
+	if o == nil || o.Backend == "" {
+		return BackendAuto
+	}
+	return o.Backend
+}
+
+// GetFile returns the path of the encrypted file, relative paths are joined with dataDir.
+func (o *Options) GetFile(dataDir string) string {
+	fileName := defaultFileName
+	if o != nil && o.File != "" {
+		fileName = o.File
+	}
+	if filepath.IsAbs(fileName) {
+		return fileName
+	}
+	return filepath.Join(dataDir, fileName)
+}
+
+// PassphraseFunc returns the passphrase of the encrypted file.
+type PassphraseFunc func() (string, error)
+
+// OpenStore returns the Store of options, which may be nil for the defaults.
+// The encrypted file is opened when it is first used, and passphrase is called then.
+func OpenStore(options *Options, dataDir string, passphrase PassphraseFunc) Store {
+	fileStore := &lazyStore{open: func() (Store, error) {
+		passphraseValue, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		store, err := NewFileStore(options.GetFile(dataDir), passphraseValue)
+		if err != nil {
+			return nil, err
+		}
+		// The passphrase is checked now rather than failing every secret later
+		if err = store.Verify(); err != nil {
+			return nil, err
+		}
+		return store, nil
+	}}
+	switch options.GetBackend() {
+	case BackendKeyring:
+		return KeyringStore{}
+	case BackendFile:
+		return fileStore
+	}
+	return &fallbackStore{primary: KeyringStore{}, fallback: fileStore}
+}
+
+// KeyringStore is a Store in the OS keyring, i.e. the Secret Service on Linux,
+// the Keychain on macOS and the Credential Manager on Windows.
+type KeyringStore struct{}
+
+func (KeyringStore) Get(name string) (string, error) {
+	secret, err := keyring.Get(keyringService, name)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	return secret, err
+}
+
+func (KeyringStore) Set(name string, secret string) error {
+	return keyring.Set(keyringService, name, secret)
+}
+
+func (KeyringStore) Delete(name string) error {
+	err := keyring.Delete(keyringService, name)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// retrier is implemented by the stores that remember the failure to open, until Retry is called.
+type retrier interface {
+	Retry()
+}
+
+// lazyStore opens the Store when it is first used. If it fails to be opened, e.g. the passphrase
+// is wrong or not entered, the error is returned without opening it again until Retry is called,
+// so that the passphrase is not asked for each secret.
+type lazyStore struct {
+	mu      sync.Mutex
+	open    func() (Store, error)
+	store   Store
+	openErr error
+}
+
+func (s *lazyStore) get() (Store, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store == nil && s.openErr == nil {
+		s.store, s.openErr = s.open()
+	}
+	return s.store, s.openErr
+}
+
+// Retry forgets the failure to open the store, it is opened again when it is used next time.
+func (s *lazyStore) Retry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.openErr = nil
+}
+
+// check drops the opened store if err means the passphrase no longer decrypts it,
+// e.g. the file is replaced by one with another passphrase.
+func (s *lazyStore) check(err error) error {
+	if errors.Is(err, ErrWrongPassphrase) {
+		s.mu.Lock()
+		s.store = nil
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *lazyStore) Get(name string) (string, error) {
+	store, err := s.get()
+	if err != nil {
+		return "", err
+	}
+	secret, err := store.Get(name)
+	return secret, s.check(err)
+}
+
+func (s *lazyStore) Set(name string, secret string) error {
+	store, err := s.get()
+	if err != nil {
+		return err
+	}
+	return s.check(store.Set(name, secret))
+}
+
+func (s *lazyStore) Delete(name string) error {
+	store, err := s.get()
+	if err != nil {
+		return err
+	}
+	return s.check(store.Delete(name))
+}
+
+// fallbackStore uses primary until it fails with an error other than ErrNotFound,
+// which means it is not available, e.g. there is no Secret Service on a headless Linux,
+// and uses fallback from then on.
+type fallbackStore struct {
+	mu           sync.Mutex
+	primary      Store
+	fallback     Store
+	usesFallback bool
+}
+
+// do calls f with primary, or with fallback if primary is not available.
+func (s *fallbackStore) do(f func(store Store) error) error {
+	s.mu.Lock()
+	usesFallback := s.usesFallback
+	s.mu.Unlock()
+	if !usesFallback {
+		err := f(s.primary)
+		if err == nil || errors.Is(err, ErrNotFound) {
+			return err
+		}
+		s.mu.Lock()
+		s.usesFallback = true
+		s.mu.Unlock()
+	}
+	return f(s.fallback)
+}
+
+func (s *fallbackStore) Retry() {
+	if r, ok := s.fallback.(retrier); ok {
+		r.Retry()
+	}
+}
+
+func (s *fallbackStore) Get(name string) (string, error) {
+	var secret string
+	err := s.do(func(store Store) error {
+		var err error
+		secret, err = store.Get(name)
+		return err
+	})
+	return secret, err
+}
+
+func (s *fallbackStore) Set(name string, secret string) error {
+	return s.do(func(store Store) error {
+		return store.Set(name, secret)
+	})
+}
+
+func (s *fallbackStore) Delete(name string) error {
+	return s.do(func(store Store) error {
+		return store.Delete(name)
+	})
+}
@@ -0,0 +1,92 @@
+package secret
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/zalando/go-keyring"
+	"path/filepath"
+	"testing"
+)
+
+// unavailableStore is a Store that always fails, like the OS keyring on a headless Linux.
+type unavailableStore struct{}
+
+func (unavailableStore) Get(name string) (string, error) {
+	return "", errors.New("the keyring is not available")
+}
+
+func (unavailableStore) Set(name string, secret string) error {
+	return errors.New("the keyring is not available")
+}
+
+func (unavailableStore) Delete(name string) error {
+	return errors.New("the keyring is not available")
+}
+
+func TestKeyringStore(t *testing.T) {
+	keyring.MockInit()
+	store := KeyringStore{}
+	_, err := store.Get("MY_PHONE")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, store.Set("MY_PHONE", "abcdefghijklmnopqrstuv"))
+	secret, err := store.Get("MY_PHONE")
+	assert.Nil(t, err)
+	assert.Equal(t, "abcdefghijklmnopqrstuv", secret)
+	assert.Nil(t, store.Delete("MY_PHONE"))
+	assert.ErrorIs(t, store.Delete("MY_PHONE"), ErrNotFound)
+}
+
+func TestFallbackStore(t *testing.T) {
+	fallback := memoryStore{"MY_PHONE": "abcdefghijklmnopqrstuv"}
+
+	// The available primary store is used, even if the secret is not found
+	store := &fallbackStore{primary: memoryStore{}, fallback: fallback}
+	_, err := store.Get("MY_PHONE")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	store = &fallbackStore{primary: unavailableStore{}, fallback: fallback}
+	secret, err := store.Get("MY_PHONE")
+	assert.Nil(t, err)
+	assert.Equal(t, "abcdefghijklmnopqrstuv", secret)
+	assert.Nil(t, store.Set("MY_IPAD", "vutsrqponmlkjihgfedcba"))
+	assert.Equal(t, "vutsrqponmlkjihgfedcba", fallback["MY_IPAD"])
+}
+
+func TestOpenStore(t *testing.T) {
+	dir := t.TempDir()
+	var passphraseCalls int
+	passphrase := func() (string, error) {
+		passphraseCalls++
+		return "correct horse", nil
+	}
+	store := OpenStore(&Options{Backend: BackendFile, File: "my-secrets.enc"}, dir, passphrase)
+	assert.Equal(t, 0, passphraseCalls, "the passphrase is not asked until the store is used")
+	_, err := store.Get("MY_PHONE")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, store.Set("MY_PHONE", "abcdefghijklmnopqrstuv"))
+	assert.Equal(t, 1, passphraseCalls)
+	assert.FileExists(t, filepath.Join(dir, "my-secrets.enc"))
+
+	// A wrong or canceled passphrase is asked only once until Retry
+	passphraseCalls = 0
+	wrongPassphrase := func() (string, error) {
+		passphraseCalls++
+		return "wrong", nil
+	}
+	wrongStore := OpenStore(&Options{Backend: BackendFile, File: "my-secrets.enc"}, dir, wrongPassphrase)
+	for i := 0; i < 3; i++ {
+		_, err = wrongStore.Get("MY_PHONE")
+		assert.ErrorIs(t, err, ErrWrongPassphrase)
+	}
+	assert.Equal(t, 1, passphraseCalls)
+	wrongStore.(retrier).Retry()
+	_, err = wrongStore.Get("MY_PHONE")
+	assert.ErrorIs(t, err, ErrWrongPassphrase)
+	assert.Equal(t, 2, passphraseCalls)
+
+	_, ok := OpenStore(nil, dir, passphrase).(*fallbackStore)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(dir, "secrets.enc"), (*Options)(nil).GetFile(dir))
+	assert.NotNil(t, (&Options{Backend: "vault"}).Validate())
+	assert.Nil(t, (&Options{}).Validate())
+}
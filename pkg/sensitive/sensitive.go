@@ -0,0 +1,137 @@
+package sensitive
+
+import (
+	"regexp"
+	"sort"
+)
+
+// Kind is the kind of a secret.
+type Kind string
+
+const (
+	KindJwt        Kind = "JWT"
+	KindApiKey     Kind = "API key"
+	KindPrivateKey Kind = "private key"
+	KindCreditCard Kind = "credit card number"
+)
+
+// Match is a secret found in a text.
+type Match struct {
+	Kind Kind
+	// Start and End are the byte offsets of the secret in the text.
+	Start int
+	End   int
+}
+
+type detector struct {
+	kind Kind
+	re   *regexp.Regexp
+	// check further checks the matched text, e.g. the checksum, if it is not nil.
+	check func(text string) bool
+}
+
+var detectors = []detector{
+	{kind: KindJwt, re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{4,}\.eyJ[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]*`)},
+	{kind: KindPrivateKey, re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`)},
+	// AWS access key ids
+	{kind: KindApiKey, re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	// GitHub tokens
+	{kind: KindApiKey, re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	// Slack tokens
+	{kind: KindApiKey, re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	// Stripe keys
+	{kind: KindApiKey, re: regexp.MustCompile(`\b(?:sk|rk|pk)_(?:live|test)_[A-Za-z0-9]{16,}\b`)},
+	// Google API keys
+	{kind: KindApiKey, re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	// "sk-" prefixed keys of the AI services, e.g. OpenAI
+	{kind: KindApiKey, re: regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`)},
+	// 13 to 19 digits starting with the major industry identifiers of the card networks,
+	// optionally grouped by spaces or dashes
+	{kind: KindCreditCard, re: regexp.MustCompile(`\b[2-6](?:[ -]?\d){12,18}\b`), check: isLuhnValid},
+}
+
+// Find returns the secrets in text in the order they appear, overlapping matches are merged
+// into the first one.
+func Find(text string) []Match {
+	var matches []Match
+	for _, d := range detectors {
+		for _, loc := range d.re.FindAllStringIndex(text, -1) {
+			if d.check != nil && !d.check(text[loc[0]:loc[1]]) {
+				continue
+			}
+			matches = append(matches, Match{Kind: d.kind, Start: loc[0], End: loc[1]})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Start < matches[j].Start
+	})
+	merged := matches[:0]
+	for _, match := range matches {
+		if len(merged) > 0 && match.Start < merged[len(merged)-1].End {
+			if match.End > merged[len(merged)-1].End {
+				merged[len(merged)-1].End = match.End
+			}
+			continue
+		}
+		merged = append(merged, match)
+	}
+	return merged
+}
+
+// Contains reports whether there is any secret in text.
+func Contains(text string) bool {
+	return len(Find(text)) > 0
+}
+
+// Kinds returns the distinct kinds of the secrets in text.
+func Kinds(text string) []Kind {
+	var kinds []Kind
+	found := make(map[Kind]bool)
+	for _, match := range Find(text) {
+		if !found[match.Kind] {
+			kinds = append(kinds, match.Kind)
+			found[match.Kind] = true
+		}
+	}
+	return kinds
+}
+
+// Mask replaces the secrets in text with "[REDACTED <kind>]".
+func Mask(text string) string {
+	matches := Find(text)
+	if len(matches) == 0 {
+		return text
+	}
+	masked := make([]byte, 0, len(text))
+	last := 0
+	for _, match := range matches {
+		masked = append(masked, text[last:match.Start]...)
+		masked = append(masked, "[REDACTED "+string(match.Kind)+"]"...)
+		last = match.End
+	}
+	masked = append(masked, text[last:]...)
+	return string(masked)
+}
+
+// isLuhnValid reports whether the digits in number, ignoring spaces and dashes,
+// pass the Luhn checksum used by the credit card numbers.
+func isLuhnValid(number string) bool {
+	sum := 0
+	digitCount := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if digitCount%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		digitCount++
+	}
+	return digitCount >= 13 && sum%10 == 0
+}
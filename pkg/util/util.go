@@ -33,6 +33,28 @@ func IsFileExists(filePath string) bool {
 	return !os.IsNotExist(err)
 }
 
+// WriteFileAtomic writes data to the specified file atomically by writing to a temporary file
+// in the same directory first and then renaming it, so the file is never left half-written.
+func WriteFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	if _, err = tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err = tempFile.Chmod(perm); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err = tempFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), filePath)
+}
+
 // IsValidHttpUrl validates if the specified raw URL is a http URL.
 func IsValidHttpUrl(rawUrl string) bool {
 	if strings.HasPrefix(rawUrl, "http://") || strings.HasPrefix(rawUrl, "https://") {
@@ -65,12 +87,12 @@ func GetExecutablePath() (string, error) {
 
 // ToAbsolutePath determines if filePath is an absolute path.
 // If it is an absolute path, it returns the original filePath directly.
-// If it is a relative path, it will be concatenated with executablePath and returned.
-func ToAbsolutePath(filePath, executablePath string) string {
+// If it is a relative path, it will be concatenated with baseDir and returned.
+func ToAbsolutePath(filePath, baseDir string) string {
 	if filepath.IsAbs(filePath) {
 		return filePath
 	}
-	return filepath.Join(executablePath, filePath)
+	return filepath.Join(baseDir, filePath)
 }
 
 // ExtractUrlFromText extracts the first URL from the specified text.
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"github.com/LGiki/bark-tray/pkg/config"
+	"github.com/LGiki/bark-tray/pkg/filewatcher"
+	"github.com/LGiki/bark-tray/pkg/hotkeys"
+	"github.com/LGiki/bark-tray/pkg/httpClient"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/LGiki/bark-tray/pkg/util"
+	"github.com/getlantern/systray"
+	"github.com/ncruces/zenity"
+	"go.uber.org/zap"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// configReloadDebounce is the time to wait for the config file to be completely written before reloading it.
+const configReloadDebounce = 500 * time.Millisecond
+
+var (
+	// reloadMu serializes the reloads triggered by the file watcher and the menu item.
+	reloadMu          sync.Mutex
+	configFileWatcher *filewatcher.Watcher
+)
+
+// reloadConfig reloads the config file and applies it to the running application.
+// If the config file is invalid, the current config is kept and the error is returned.
+func reloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	newConfig, err := config.LoadConfig(appConfigFilePath)
+	if err != nil {
+		return err
+	}
+	oldConfig := appConfig.Load()
+
+	if newConfig.EnableLog {
+		newConfig.LogFilePath = util.ToAbsolutePath(newConfig.LogFilePath, appDataDir)
+	}
+	if err = newConfig.Validate(); err != nil {
+		return err
+	}
+	if err = resolveAppSecrets(newConfig); err != nil {
+		return err
+	}
+	_ = logger.SetRedaction(newConfig.LogRedaction)
+	if newConfig.EnableLog != oldConfig.EnableLog || newConfig.LogFilePath != oldConfig.LogFilePath ||
+		!reflect.DeepEqual(newConfig.Log, oldConfig.Log) {
+		// InitLogger replaces the current logger, which keeps logging if the new one fails
+		if !newConfig.EnableLog {
+			_ = logger.Close()
+		} else if err = logger.InitLogger(newConfig.LogFilePath, newConfig.Log); err != nil {
+			_ = zenity.Notify("Failed to initialize logger: "+err.Error(), zenity.ErrorIcon)
+		}
+	}
+	newConfig.StripInvalidDevices()
+	newConfig.StripInvalidProfiles()
+	newConfig.StripInvalidGroups()
+	if newConfig.UserAgent != oldConfig.UserAgent || newConfig.Timeout != oldConfig.Timeout {
+		httpClient.Setup(newConfig.UserAgent, newConfig.Timeout)
+	}
+
+	appConfig.Store(newConfig)
+	updatePushMenu()
+	reloadClipboardWatcher()
+	reloadPushHistory()
+	hotkeys.UnregisterAll()
+	registerHotkeys()
+	if !reflect.DeepEqual(oldConfig.LocalApi, newConfig.LocalApi) {
+		stopLocalApi()
+		startLocalApi()
+	}
+	logger.Info("Config file reloaded", zap.Int("devices", len(newConfig.Devices)))
+	return nil
+}
+
+// notifyReloadError shows a notification for the error returned by reloadConfig.
+func notifyReloadError(err error) {
+	logMessage := "Failed to reload config file, the current config is kept: " + err.Error()
+	logger.Error(logMessage)
+	_ = zenity.Notify(logMessage, zenity.ErrorIcon)
+}
+
+func addReloadConfigMenuItem() {
+	reloadConfigMenuItem := systray.AddMenuItem("Reload config", "Reload the config file")
+	go func() {
+		for range reloadConfigMenuItem.ClickedCh {
+			if err := reloadConfig(); err != nil {
+				notifyReloadError(err)
+				continue
+			}
+			_ = zenity.Notify("Config file reloaded", zenity.InfoIcon)
+		}
+	}()
+}
+
+// watchConfigFile reloads the config file automatically when it is changed.
+func watchConfigFile() {
+	var err error
+	configFileWatcher, err = filewatcher.Watch(appConfigFilePath, configReloadDebounce, func() {
+		logger.Info("Config file changed, reloading")
+		if err := reloadConfig(); err != nil {
+			notifyReloadError(err)
+		}
+	})
+	if err != nil {
+		logger.Warn("Failed to watch the config file, it will not be reloaded automatically: " + err.Error())
+	}
+}
+
+func stopWatchingConfigFile() {
+	if configFileWatcher != nil {
+		configFileWatcher.Stop()
+	}
+}
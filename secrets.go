@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LGiki/bark-tray/pkg/config"
+	"github.com/LGiki/bark-tray/pkg/secret"
+	"github.com/ncruces/zenity"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// appSecretResolver resolves the device keys that are secret references, it is kept
+// across reloads so that the passphrase of the encrypted file is asked only once.
+var (
+	appSecretResolver *secret.Resolver
+	// appSecretStoreOptions is the options appSecretResolver is created with.
+	appSecretStoreOptions *secret.Options
+)
+
+// newSecretResolver creates the resolver of the secret references in the config file,
+// passphrase is called when the passphrase of the encrypted file is first needed.
+func newSecretResolver(options *secret.Options, configFilePath string, dataDir string, passphrase secret.PassphraseFunc) *secret.Resolver {
+	return &secret.Resolver{
+		Store:   secret.OpenStore(options, dataDir, passphrase),
+		BaseDir: filepath.Dir(configFilePath),
+	}
+}
+
+// resolveAppSecrets resolves the secret references in newConfig with appSecretResolver,
+// which is recreated if the secret store setting of newConfig is changed. If the encrypted
+// file failed to be opened before, e.g. due to a wrong passphrase, the passphrase is asked again.
+func resolveAppSecrets(newConfig *config.Config) error {
+	if appSecretResolver == nil || !reflect.DeepEqual(appSecretStoreOptions, newConfig.SecretStore) {
+		appSecretResolver = newSecretResolver(newConfig.SecretStore, appConfigFilePath, appDataDir, askPassphrase)
+		appSecretStoreOptions = newConfig.SecretStore
+	} else {
+		appSecretResolver.Retry()
+	}
+	return newConfig.ResolveSecrets(appSecretResolver)
+}
+
+// askPassphrase returns the passphrase in the BARK_TRAY_PASSPHRASE environment variable,
+// or asks the user for it if the variable is not set.
+func askPassphrase() (string, error) {
+	if passphrase := os.Getenv(secret.PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	_, passphrase, err := zenity.Password(
+		zenity.Title("Passphrase of the secret file"),
+		zenity.OKLabel("OK"),
+		zenity.CancelLabel("Cancel"),
+	)
+	if err != nil {
+		return "", fmt.Errorf("the passphrase of the secret file is not entered: %s", err.Error())
+	}
+	return passphrase, nil
+}
+
+// envPassphrase returns the passphrase in the BARK_TRAY_PASSPHRASE environment variable,
+// it is used by the command-line interface, which cannot show dialogs.
+func envPassphrase() (string, error) {
+	if passphrase := os.Getenv(secret.PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	return "", errors.New("the OS keyring is not available, set " + secret.PassphraseEnv + " to use the secret file")
+}
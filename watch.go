@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"github.com/LGiki/bark-tray/pkg/clipwatcher"
+	"github.com/LGiki/bark-tray/pkg/config"
+	"github.com/LGiki/bark-tray/pkg/logger"
+	"github.com/getlantern/systray"
+	"github.com/ncruces/zenity"
+	"golang.design/x/clipboard"
+	"sync"
+)
+
+var (
+	// watchMu guards clipboardWatcher, which is replaced when the config is reloaded.
+	watchMu                sync.Mutex
+	clipboardWatcher       *clipwatcher.Watcher
+	watchClipboardMenuItem *systray.MenuItem
+)
+
+func watchClipboardText(ctx context.Context) <-chan []byte {
+	return clipboard.Watch(ctx, clipboard.FmtText)
+}
+
+// pushWatchedText sends the text copied in the "Watch clipboard" mode to the default device.
+func pushWatchedText(text string) {
+	defaultDevice := appConfig.Load().GetDefaultDevice()
+	if defaultDevice == nil {
+		return
+	}
+	pushRequest := config.NewTextPushRequest(text)
+	if check := guardSecrets([]*config.Device{defaultDevice}, pushRequest, true); len(check.Allowed) == 0 {
+		return
+	}
+	pushToDevice(defaultDevice, pushRequest)
+}
+
+// writeClipboardText writes text to the clipboard without the "Watch clipboard" mode sending it again.
+func writeClipboardText(text string) {
+	watchMu.Lock()
+	if clipboardWatcher != nil {
+		clipboardWatcher.Ignore(text)
+	}
+	watchMu.Unlock()
+	clipboard.Write(clipboard.FmtText, []byte(text))
+}
+
+// newClipboardWatcher creates the watcher with the watchClipboard config in appConfig.
+func newClipboardWatcher() (*clipwatcher.Watcher, *clipwatcher.Options, error) {
+	options := appConfig.Load().WatchClipboard
+	if options == nil {
+		options = &clipwatcher.Options{}
+	}
+	watcher, err := clipwatcher.New(options, watchClipboardText, pushWatchedText)
+	if err != nil {
+		logMessage := "Invalid watchClipboard config: " + err.Error()
+		logger.Error(logMessage)
+		_ = zenity.Notify(logMessage, zenity.ErrorIcon)
+		return nil, nil, err
+	}
+	return watcher, options, nil
+}
+
+// addWatchClipboardMenuItem adds the "Watch clipboard" checkbox,
+// which is only available when there is a default device.
+func addWatchClipboardMenuItem() {
+	watchClipboardMenuItem = systray.AddMenuItemCheckbox("Watch clipboard", "Send every new text copied to the default device", false)
+	watcher, options, err := newClipboardWatcher()
+	watchMu.Lock()
+	clipboardWatcher = watcher
+	if err != nil || !appConfig.Load().IsDefaultDeviceExist() {
+		watchClipboardMenuItem.Disable()
+	} else if options.Enabled {
+		clipboardWatcher.Start()
+		watchClipboardMenuItem.Check()
+		logger.Info("Start watching the clipboard")
+	}
+	watchMu.Unlock()
+	go func() {
+		for range watchClipboardMenuItem.ClickedCh {
+			watchMu.Lock()
+			toggleClipboardWatcher()
+			watchMu.Unlock()
+		}
+	}()
+}
+
+// toggleClipboardWatcher starts or stops watching, watchMu must be held.
+func toggleClipboardWatcher() {
+	if clipboardWatcher == nil {
+		return
+	}
+	if clipboardWatcher.IsRunning() {
+		clipboardWatcher.Stop()
+		watchClipboardMenuItem.Uncheck()
+		logger.Info("Stop watching the clipboard")
+	} else {
+		clipboardWatcher.Start()
+		watchClipboardMenuItem.Check()
+		logger.Info("Start watching the clipboard")
+	}
+}
+
+// reloadClipboardWatcher replaces the watcher with one using the reloaded config,
+// watching continues if it was running and there is still a default device.
+func reloadClipboardWatcher() {
+	watcher, _, err := newClipboardWatcher()
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	if err != nil {
+		// Keep the current watcher
+		return
+	}
+	wasRunning := false
+	if clipboardWatcher != nil {
+		wasRunning = clipboardWatcher.IsRunning()
+		clipboardWatcher.Stop()
+	}
+	clipboardWatcher = watcher
+	if !appConfig.Load().IsDefaultDeviceExist() {
+		watchClipboardMenuItem.Uncheck()
+		watchClipboardMenuItem.Disable()
+		if wasRunning {
+			logger.Info("Stop watching the clipboard because there is no default device")
+		}
+		return
+	}
+	watchClipboardMenuItem.Enable()
+	if wasRunning {
+		clipboardWatcher.Start()
+	}
+}
+
+func stopClipboardWatcher() {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	if clipboardWatcher != nil {
+		clipboardWatcher.Stop()
+	}
+}